@@ -0,0 +1,55 @@
+package adk
+
+import "context"
+
+// Limiter bounds how many tool executions run at once. One Limiter can be
+// shared by several tools that hit the same backend, e.g. every tool that
+// calls the Kubernetes API server.
+type Limiter chan struct{}
+
+func NewLimiter(limit int) Limiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return make(Limiter, limit)
+}
+
+// LimitedTool runs the wrapped tool only while holding a slot of its Limiter.
+type LimitedTool struct {
+	tool    Tool
+	limiter Limiter
+}
+
+func WithLimit(tool Tool, limiter Limiter) *LimitedTool {
+	return &LimitedTool{tool: tool, limiter: limiter}
+}
+
+func (t *LimitedTool) Name() string {
+	return t.tool.Name()
+}
+
+func (t *LimitedTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	select {
+	case t.limiter <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-t.limiter }()
+	return t.tool.Execute(ctx, input)
+}
+
+func (t *LimitedTool) Unwrap() Tool {
+	return t.tool
+}
+
+// Unwrap returns the innermost tool behind any wrappers, for callers that
+// need the concrete tool type.
+func Unwrap(tool Tool) Tool {
+	for {
+		wrapper, ok := tool.(interface{ Unwrap() Tool })
+		if !ok {
+			return tool
+		}
+		tool = wrapper.Unwrap()
+	}
+}
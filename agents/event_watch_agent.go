@@ -0,0 +1,376 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// eventSummary aggregates the occurrences of one reason on one object.
+type eventSummary struct {
+	Reason    string
+	Category  tools.EventCategory
+	Container string
+	Message   string
+	// Count adds up the counts of the distinct events with the reason
+	Count     int32
+	FirstSeen time.Time
+	LastSeen  time.Time
+	counts    map[types.UID]int32
+}
+
+// observe records the latest count of event, which the API server raises
+// in place for a repeated event, and adds up the counts of all events.
+func (s *eventSummary) observe(event *corev1.Event) {
+	uid := event.UID
+	if uid == "" {
+		uid = types.UID(event.Namespace + "/" + event.Name)
+	}
+	if count := tools.EventCount(event); count > s.counts[uid] {
+		s.counts[uid] = count
+	}
+	s.sum()
+}
+
+func (s *eventSummary) sum() {
+	s.Count = 0
+	for _, count := range s.counts {
+		s.Count += count
+	}
+}
+
+// merge adds the events of other, a summary of the same reason.
+func (s *eventSummary) merge(other *eventSummary) {
+	for uid, count := range other.counts {
+		if count > s.counts[uid] {
+			s.counts[uid] = count
+		}
+	}
+	s.sum()
+	if other.FirstSeen.Before(s.FirstSeen) {
+		s.FirstSeen = other.FirstSeen
+	}
+	if other.LastSeen.After(s.LastSeen) {
+		s.LastSeen, s.Message = other.LastSeen, other.Message
+	}
+}
+
+// eventGroup collects the Warning events of one involved object until the
+// aggregation window closes.
+type eventGroup struct {
+	Namespace string
+	Kind      string
+	Name      string
+	reasons   map[string]*eventSummary // key: reason/container
+}
+
+// failures returns one failure per reason, most frequent first.
+func (g *eventGroup) failures() []tools.Failure {
+	summaries := make([]*eventSummary, 0, len(g.reasons))
+	for _, summary := range g.reasons {
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Reason < summaries[j].Reason
+	})
+
+	failures := make([]tools.Failure, 0, len(summaries))
+	for _, s := range summaries {
+		message := fmt.Sprintf("[%s] %s: %s", s.Category, s.Reason, s.Message)
+		if s.Container != "" {
+			message = fmt.Sprintf("[%s] %s (container %s): %s", s.Category, s.Reason, s.Container, s.Message)
+		}
+		failures = append(failures, tools.Failure{
+			Message:   message,
+			Category:  string(s.Category),
+			FirstSeen: s.FirstSeen,
+			LastSeen:  s.LastSeen,
+			Count:     int(s.Count),
+		})
+	}
+	return failures
+}
+
+// EventWatchAgent treats Kubernetes Warning events as failure signals of
+// their own. Events are aggregated per involved object for a short window so
+// a burst of FailedMount or BackOff events results in a single analysis, and
+// an object is re-analyzed within the cooldown only when a new reason shows up.
+type EventWatchAgent struct {
+	monitor    *LogMonitorAgent
+	filter     *tools.ScanFilter
+	factory    informers.SharedInformerFactory
+	informer   cache.SharedIndexInformer
+	pods       cache.SharedIndexInformer
+	queue      workqueue.TypedRateLimitingInterface[string]
+	window     time.Duration
+	cooldown   time.Duration
+	maxAge     time.Duration
+	maxRetries int
+
+	mu       sync.Mutex
+	groups   map[string]*eventGroup     // key: namespace|kind|name
+	notified map[string]map[string]bool // reasons already reported in the cooldown
+	lastRun  map[string]time.Time
+}
+
+// NewEventWatchAgent creates an EventWatchAgent. factory must not carry the
+// pod label or field selectors, since those do not apply to events; the pod
+// informer pods is used to apply the ScanFilter to pod events and may be nil. Events last seen
+// more than maxAge ago, such as the backlog listed at startup, are ignored.
+func NewEventWatchAgent(factory informers.SharedInformerFactory, pods cache.SharedIndexInformer, monitor *LogMonitorAgent, filter *tools.ScanFilter, window, cooldown, maxAge time.Duration, maxRetries int) *EventWatchAgent {
+	agent := &EventWatchAgent{
+		monitor:  monitor,
+		filter:   filter,
+		factory:  factory,
+		informer: factory.Core().V1().Events().Informer(),
+		pods:     pods,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "event_watch"},
+		),
+		window:     window,
+		cooldown:   cooldown,
+		maxAge:     maxAge,
+		maxRetries: maxRetries,
+		groups:     make(map[string]*eventGroup),
+		notified:   make(map[string]map[string]bool),
+		lastRun:    make(map[string]time.Time),
+	}
+
+	agent.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if event, ok := obj.(*corev1.Event); ok {
+				agent.record(event)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldEvent, ok := oldObj.(*corev1.Event)
+			if !ok {
+				return
+			}
+			// Repeated events are updated in place with a higher count
+			newEvent, ok := newObj.(*corev1.Event)
+			if ok && tools.EventCount(newEvent) > tools.EventCount(oldEvent) {
+				agent.record(newEvent)
+			}
+		},
+	})
+
+	return agent
+}
+
+// record adds a Warning event to its object's group and schedules the group
+// for analysis when the aggregation window closes.
+func (a *EventWatchAgent) record(event *corev1.Event) {
+	if !tools.IsWarningEvent(event) {
+		return
+	}
+	lastSeen := tools.EventLastSeen(event)
+	if a.maxAge > 0 && time.Since(lastSeen) > a.maxAge {
+		return
+	}
+	involved := event.InvolvedObject
+	namespace := involved.Namespace
+	if namespace == "" {
+		namespace = event.Namespace
+	}
+	container := tools.EventContainerName(event)
+	if !a.matches(namespace, involved.Kind, involved.Name, container) {
+		return
+	}
+
+	key := strings.Join([]string{namespace, involved.Kind, involved.Name}, "|")
+	a.mu.Lock()
+	group, ok := a.groups[key]
+	if !ok {
+		group = &eventGroup{
+			Namespace: namespace,
+			Kind:      involved.Kind,
+			Name:      involved.Name,
+			reasons:   make(map[string]*eventSummary),
+		}
+		a.groups[key] = group
+	}
+	reasonKey := event.Reason + "/" + container
+	summary, ok := group.reasons[reasonKey]
+	if !ok {
+		summary = &eventSummary{
+			Reason:    event.Reason,
+			Category:  tools.ClassifyEvent(event.Reason, event.Message),
+			Container: container,
+			counts:    make(map[types.UID]int32),
+		}
+		group.reasons[reasonKey] = summary
+	}
+	summary.Message = event.Message
+	summary.observe(event)
+	summary.LastSeen = lastSeen
+	if first := tools.EventFirstSeen(event); summary.FirstSeen.IsZero() || first.Before(summary.FirstSeen) {
+		summary.FirstSeen = first
+	}
+	a.mu.Unlock()
+
+	// The delaying queue keeps the earliest deadline, so the window starts
+	// with the first event of a burst
+	a.queue.AddAfter(key, a.window)
+}
+
+// matches applies the ScanFilter. Pod events are matched against the pod
+// itself once the pod cache has synced; events of other objects only by
+// namespace.
+func (a *EventWatchAgent) matches(namespace, kind, name, container string) bool {
+	if !a.filter.MatchNamespace(namespace) {
+		return false
+	}
+	if kind != "Pod" || a.pods == nil || !a.pods.HasSynced() {
+		return true
+	}
+	obj, exists, err := a.pods.GetIndexer().GetByKey(namespace + "/" + name)
+	if err != nil {
+		return true
+	}
+	// Either deleted or not selected by the pod informer's selectors
+	if !exists {
+		return false
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !a.filter.MatchPod(pod) {
+		return false
+	}
+	return container == "" || a.filter.MatchContainer(pod, container)
+}
+
+// Run starts the event informer and blocks until ctx is cancelled.
+func (a *EventWatchAgent) Run(ctx context.Context, workers int) error {
+	defer utilruntime.HandleCrash()
+	defer a.queue.ShutDown()
+
+	a.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), a.informer.HasSynced) {
+		return fmt.Errorf("failed to sync event informer cache")
+	}
+
+	log.Printf("Event watch started with %d workers", workers)
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, a.runWorker, time.Second)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (a *EventWatchAgent) runWorker(ctx context.Context) {
+	for a.processNextItem(ctx) {
+	}
+}
+
+func (a *EventWatchAgent) processNextItem(ctx context.Context) bool {
+	key, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer a.queue.Done(key)
+
+	group, failures := a.take(key)
+	if group == nil || len(failures) == 0 {
+		a.queue.Forget(key)
+		return true
+	}
+
+	result, err := a.monitor.AnalyzeEvents(ctx, group.Namespace, group.Kind, group.Name, failures)
+	if err != nil {
+		if a.queue.NumRequeues(key) < a.maxRetries {
+			log.Printf("Event analysis failed for %s, retrying: %v", key, err)
+			a.restore(key, group)
+			a.queue.AddRateLimited(key)
+			return true
+		}
+		log.Printf("Event analysis failed for %s, giving up: %v", key, err)
+		a.queue.Forget(key)
+		return true
+	}
+	a.queue.Forget(key)
+
+	if result.HasFailures() {
+		log.Printf("%s: %s/%s (Warning events)\n%s\n", group.Kind, group.Namespace, group.Name, result)
+	}
+	return true
+}
+
+// take removes the group for key and returns it with the failures that have
+// not been reported during the cooldown.
+func (a *EventWatchAgent) take(key string) (*eventGroup, []tools.Failure) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	group, ok := a.groups[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(a.groups, key)
+
+	// Forget objects whose cooldown has expired
+	for runKey, last := range a.lastRun {
+		if time.Since(last) > a.cooldown {
+			delete(a.lastRun, runKey)
+			delete(a.notified, runKey)
+		}
+	}
+	notified := a.notified[key]
+	if notified == nil {
+		notified = make(map[string]bool)
+		a.notified[key] = notified
+	}
+
+	// Only new reasons re-trigger an analysis within the cooldown, but the
+	// analysis then sees every reason of the window
+	fresh := false
+	for reasonKey := range group.reasons {
+		if !notified[reasonKey] {
+			fresh = true
+			notified[reasonKey] = true
+		}
+	}
+	if !fresh {
+		return group, nil
+	}
+	a.lastRun[key] = time.Now()
+	return group, group.failures()
+}
+
+// restore puts a group back after a failed analysis so the retry sees it.
+func (a *EventWatchAgent) restore(key string, group *eventGroup) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if current, ok := a.groups[key]; ok {
+		for reasonKey, summary := range group.reasons {
+			if existing, exists := current.reasons[reasonKey]; exists {
+				existing.merge(summary)
+			} else {
+				current.reasons[reasonKey] = summary
+			}
+		}
+	} else {
+		a.groups[key] = group
+	}
+	for reasonKey := range group.reasons {
+		delete(a.notified[key], reasonKey)
+	}
+}
@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func warningEvent(uid, reason string, count int32) *corev1.Event {
+	now := time.Now()
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "shop", Name: "api-0." + uid, UID: types.UID(uid)},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "shop", Name: "api-0"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Message:        reason + " message",
+		Count:          count,
+		FirstTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+		LastTimestamp:  metav1.NewTime(now),
+	}
+}
+
+func TestEventWatchAgentCounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []*corev1.Event
+		want   map[string]int32 // key: reason
+	}{
+		{
+			name:   "one event",
+			events: []*corev1.Event{warningEvent("e1", "FailedMount", 3)},
+			want:   map[string]int32{"FailedMount": 3},
+		},
+		{
+			name:   "event updated in place",
+			events: []*corev1.Event{warningEvent("e1", "FailedMount", 3), warningEvent("e1", "FailedMount", 5)},
+			want:   map[string]int32{"FailedMount": 5},
+		},
+		{
+			name: "distinct events with the same reason",
+			events: []*corev1.Event{
+				warningEvent("e1", "FailedMount", 3),
+				warningEvent("e2", "FailedMount", 2),
+				warningEvent("e1", "FailedMount", 4),
+			},
+			want: map[string]int32{"FailedMount": 6},
+		},
+		{
+			name:   "different reasons",
+			events: []*corev1.Event{warningEvent("e1", "FailedMount", 3), warningEvent("e2", "BackOff", 7)},
+			want:   map[string]int32{"FailedMount": 3, "BackOff": 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			agent := NewEventWatchAgent(factory, nil, nil, nil, time.Minute, time.Hour, 0, 3)
+			defer agent.queue.ShutDown()
+			for _, event := range tt.events {
+				agent.record(event)
+			}
+			group := agent.groups["shop|Pod|api-0"]
+			if group == nil {
+				t.Fatal("record() made no group for the pod")
+			}
+			got := make(map[string]int32)
+			for _, summary := range group.reasons {
+				got[summary.Reason] = summary.Count
+			}
+			for reason, want := range tt.want {
+				if got[reason] != want {
+					t.Errorf("count of %s = %d, want %d", reason, got[reason], want)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("reasons = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventWatchAgentRestore(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	agent := NewEventWatchAgent(factory, nil, nil, nil, time.Minute, time.Hour, 0, 3)
+	defer agent.queue.ShutDown()
+	key := "shop|Pod|api-0"
+
+	agent.record(warningEvent("e1", "FailedMount", 3))
+	group, failures := agent.take(key)
+	if len(failures) != 1 {
+		t.Fatalf("take() = %d failures, want 1", len(failures))
+	}
+	// More events arrive while the failed analysis runs
+	agent.record(warningEvent("e1", "FailedMount", 4))
+	agent.record(warningEvent("e2", "FailedMount", 2))
+	agent.restore(key, group)
+
+	_, failures = agent.take(key)
+	if len(failures) != 1 || failures[0].Count != 6 {
+		t.Errorf("take() after restore = %v, want FailedMount x6", failures)
+	}
+}
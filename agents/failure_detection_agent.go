@@ -1,30 +1,20 @@
 package agents
 
 import (
-	"regexp"
+	"strings"
+
+	"github.com/vasudevchavan/K8sLogmonitor/detection"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
 )
 
 type FailureDetectionAgent struct {
-	patterns []*regexp.Regexp
+	engine *detection.Engine
 }
 
-func NewFailureDetectionAgent() *FailureDetectionAgent {
-	patternStrings := []string{
-		"panic:", "error:", "failed to .*", "connection refused",
-		"pull image", "startup error", "waiting to start", "imagepullbackoff",
-	}
-	patterns := make([]*regexp.Regexp, len(patternStrings))
-	for i, p := range patternStrings {
-		patterns[i] = regexp.MustCompile("(?i)" + p)
-	}
-	return &FailureDetectionAgent{patterns: patterns}
+func NewFailureDetectionAgent(engine *detection.Engine) *FailureDetectionAgent {
+	return &FailureDetectionAgent{engine: engine}
 }
 
-func (a *FailureDetectionAgent) DetectFailures(logs string) []string {
-	var failures []string
-	for _, re := range a.patterns {
-		matches := re.FindAllString(logs, -1)
-		failures = append(failures, matches...)
-	}
-	return failures
+func (a *FailureDetectionAgent) DetectFailures(logs string) []tools.Failure {
+	return a.engine.Detect(logs, tools.DetectFormat(strings.Split(logs, "\n")))
 }
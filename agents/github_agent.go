@@ -46,11 +46,36 @@ func (a *GitHubAgent) Execute(ctx context.Context, input string) (string, error)
 	}
 	
 	// Format issues for LLM if GitHub tool is available
-	if gt, ok := githubTool.(*tools.GitHubTool); ok {
+	if gt, ok := adk.Unwrap(githubTool).(*tools.GitHubTool); ok {
 		if issueList, ok := issues.([]tools.GitHubIssue); ok {
 			return gt.FormatIssuesForLLM(issueList), nil
 		}
 	}
 	
 	return "No related GitHub issues found.", nil
+}
+
+// FindLinked returns the issues of repo that mention one of the failure
+// fingerprints, formatted for the LLM, or "" when there are none. A team
+// links an issue to a failure by putting its fingerprint in the issue.
+func (a *GitHubAgent) FindLinked(ctx context.Context, fingerprints []string, repo string) (string, error) {
+	githubTool, exists := a.registry.GetTool("github_issues")
+	if !exists {
+		return "", fmt.Errorf("github_issues tool not found")
+	}
+	
+	issues, err := githubTool.Execute(ctx, map[string]interface{}{
+		"fingerprints": fingerprints,
+		"repo":         repo,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search linked GitHub issues: %w", err)
+	}
+	
+	gt, ok := adk.Unwrap(githubTool).(*tools.GitHubTool)
+	issueList, isList := issues.([]tools.GitHubIssue)
+	if !ok || !isList {
+		return "", nil
+	}
+	return gt.FormatLinkedIssuesForLLM(issueList), nil
 }
\ No newline at end of file
@@ -0,0 +1,204 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+)
+
+// maxIncidentPodsInContext bounds how many affected pods are named in the
+// LLM context of an incident.
+const maxIncidentPodsInContext = 10
+
+// Incident is a failure shared by the replicas of a workload: the failures
+// with the same fingerprint, found in one container of the workload's pods.
+// It is recommended on once, however many pods are affected.
+type Incident struct {
+	// Fingerprint is the fingerprint of the failure; see
+	// tools.FailureFingerprint
+	Fingerprint   string
+	Namespace     string
+	Workload      tools.Workload
+	ContainerName string
+	Kind          tools.ContainerKind
+	// Pods are the affected pods, in the order they were analyzed
+	Pods []string
+	// Failure is merged across the pods; counts add up and the line and
+	// context are those of the first pod. A failure hidden by a suppression
+	// or silence says which one in SuppressedBy and is not recommended on.
+	Failure        tools.Failure
+	RulesVersion   string
+	Recommendation string
+
+	// podContext is the first pod's Kubernetes context for the LLM
+	podContext string
+}
+
+// Suppressed reports whether the incident's failure was hidden by a
+// suppression or silence.
+func (i *Incident) Suppressed() bool {
+	return i.Failure.SuppressedBy != ""
+}
+
+// String renders the incident the way the CLI prints it.
+func (i *Incident) String() string {
+	s := fmt.Sprintf("Incident %s: %s/%s, container %s (%s container)\nAffected pods (%d): %s\n",
+		i.Fingerprint, i.Namespace, i.Workload, i.ContainerName, i.Kind, len(i.Pods), strings.Join(i.Pods, ", "))
+	switch {
+	case i.Suppressed():
+		s += fmt.Sprintf("Suppressed: %s [%s]", i.Failure, i.Failure.SuppressedBy)
+	case i.Recommendation == "":
+		s += fmt.Sprintf("Failure detected: %s", i.Failure)
+	default:
+		s += fmt.Sprintf("Failure: %s\nRecommendation: %s", i.Failure, i.Recommendation)
+	}
+	if i.RulesVersion != "" {
+		s += "\nRules version: " + i.RulesVersion
+	}
+	return s
+}
+
+// GroupIncidents groups the failures of analyses into incidents, one per
+// workload container and failure signature, which the fingerprint of a
+// failure stands for. Incidents are in the order their failure was first
+// found; suppressed failures form incidents of their own.
+func GroupIncidents(results []*AnalysisResult) []*Incident {
+	var incidents []*Incident
+	index := make(map[string]*Incident)
+	failures := make(map[string][]tools.Failure)
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, failure := range append(append([]tools.Failure(nil), result.Failures...), result.Suppressed...) {
+			incident, ok := index[failure.Fingerprint]
+			if !ok {
+				incident = &Incident{
+					Fingerprint:   failure.Fingerprint,
+					Namespace:     result.Namespace,
+					Workload:      result.Workload,
+					ContainerName: result.ContainerName,
+					Kind:          result.Kind,
+					RulesVersion:  result.RulesVersion,
+					podContext:    result.podContext,
+				}
+				index[failure.Fingerprint] = incident
+				incidents = append(incidents, incident)
+			}
+			// A pod logging the same failure under several rules is listed
+			// once
+			if len(incident.Pods) == 0 || incident.Pods[len(incident.Pods)-1] != result.PodName {
+				incident.Pods = append(incident.Pods, result.PodName)
+			}
+			failures[failure.Fingerprint] = append(failures[failure.Fingerprint], failure)
+		}
+	}
+	for _, incident := range incidents {
+		incident.Failure = tools.MergeFailures(failures[incident.Fingerprint], fingerprintKey)[0]
+	}
+	return incidents
+}
+
+func fingerprintKey(failure tools.Failure) string {
+	return failure.Fingerprint
+}
+
+// RecommendIncident makes the incident's one recommendation, from the first
+// pod's context and the list of affected pods. Suppressed incidents get
+// none.
+func (a *LogMonitorAgent) RecommendIncident(ctx context.Context, incident *Incident) error {
+	if incident.Suppressed() {
+		return nil
+	}
+	pods := strings.Join(incident.Pods, ", ")
+	if len(incident.Pods) > maxIncidentPodsInContext {
+		pods = strings.Join(incident.Pods[:maxIncidentPodsInContext], ", ") + ", ..."
+	}
+	contextStr := failureContext(incident.Pods[0], incident.Namespace, incident.ContainerName, incident.Kind,
+		[]tools.Failure{incident.Failure}, incident.podContext)
+	contextStr += fmt.Sprintf("Workload: %s, %d affected pods with the same failure: %s\n",
+		incident.Workload, len(incident.Pods), pods)
+	recommendation, err := a.recommend(ctx, incident.Workload.Name, []tools.Failure{incident.Failure}, contextStr)
+	if err != nil {
+		return err
+	}
+	incident.Recommendation = recommendation
+	return nil
+}
+
+// IncidentTracker keeps the incidents a long-running watch has reported
+// open, so replicas of a workload that fail the same way join the open
+// incident instead of being reported and recommended on again. An incident
+// closes once its failure has not been seen for the tracker's window.
+type IncidentTracker struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	open map[string]*trackedIncident // key: fingerprint
+}
+
+type trackedIncident struct {
+	incident *Incident
+	lastSeen time.Time
+}
+
+// NewIncidentTracker creates a tracker keeping incidents open for window
+// after their failure was last seen.
+func NewIncidentTracker(window time.Duration) *IncidentTracker {
+	return &IncidentTracker{window: window, open: make(map[string]*trackedIncident)}
+}
+
+// Split groups the failures of result into incidents and separates the new
+// ones from those joining an open incident. Joined incidents are copies of
+// the open ones, with the result's pod added.
+func (t *IncidentTracker) Split(result *AnalysisResult, now time.Time) (fresh, joined []*Incident) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for fingerprint, tracked := range t.open {
+		if now.Sub(tracked.lastSeen) >= t.window {
+			delete(t.open, fingerprint)
+		}
+	}
+	for _, incident := range GroupIncidents([]*AnalysisResult{result}) {
+		tracked, ok := t.open[incident.Fingerprint]
+		if !ok {
+			fresh = append(fresh, incident)
+			continue
+		}
+		tracked.lastSeen = now
+		if !slices.Contains(tracked.incident.Pods, result.PodName) {
+			tracked.incident.Pods = append(tracked.incident.Pods, result.PodName)
+		}
+		// The open incident is shared with other workers; callers get a copy
+		incident := *tracked.incident
+		incident.Pods = slices.Clone(incident.Pods)
+		joined = append(joined, &incident)
+	}
+	return fresh, joined
+}
+
+// Open records reported incidents as open. An incident another worker
+// opened meanwhile keeps its recommendation and gains the pods.
+func (t *IncidentTracker) Open(incidents []*Incident, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, incident := range incidents {
+		if tracked, ok := t.open[incident.Fingerprint]; ok {
+			for _, pod := range incident.Pods {
+				if !slices.Contains(tracked.incident.Pods, pod) {
+					tracked.incident.Pods = append(tracked.incident.Pods, pod)
+				}
+			}
+			tracked.lastSeen = now
+			continue
+		}
+		t.open[incident.Fingerprint] = &trackedIncident{incident: incident, lastSeen: now}
+	}
+}
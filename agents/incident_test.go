@@ -0,0 +1,175 @@
+package agents
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+)
+
+func analysis(pod string, failures ...tools.Failure) *AnalysisResult {
+	return &AnalysisResult{
+		Namespace:     "shop",
+		PodName:       pod,
+		ContainerName: "app",
+		Workload:      tools.Workload{Kind: "Deployment", Name: "api"},
+		Failures:      failures,
+	}
+}
+
+func failure(fingerprint string, count int) tools.Failure {
+	return tools.Failure{Message: "failure " + fingerprint, Fingerprint: fingerprint, Count: count}
+}
+
+func TestGroupIncidents(t *testing.T) {
+	suppressed := failure("c", 1)
+	suppressed.SuppressedBy = "silence s1"
+	tests := []struct {
+		name    string
+		results []*AnalysisResult
+		want    []Incident // fingerprint, pods, merged count and suppression
+	}{
+		{
+			name:    "replicas with the same failure",
+			results: []*AnalysisResult{analysis("api-1", failure("a", 2)), analysis("api-2", failure("a", 3))},
+			want:    []Incident{{Fingerprint: "a", Pods: []string{"api-1", "api-2"}, Failure: tools.Failure{Count: 5}}},
+		},
+		{
+			name: "different failures",
+			results: []*AnalysisResult{
+				analysis("api-1", failure("a", 1), failure("b", 1)),
+				analysis("api-2", failure("b", 1)),
+			},
+			want: []Incident{
+				{Fingerprint: "a", Pods: []string{"api-1"}, Failure: tools.Failure{Count: 1}},
+				{Fingerprint: "b", Pods: []string{"api-1", "api-2"}, Failure: tools.Failure{Count: 2}},
+			},
+		},
+		{
+			name:    "pod listed once for several matches",
+			results: []*AnalysisResult{analysis("api-1", failure("a", 1), failure("a", 4))},
+			want:    []Incident{{Fingerprint: "a", Pods: []string{"api-1"}, Failure: tools.Failure{Count: 5}}},
+		},
+		{
+			name: "suppressed failures apart",
+			results: []*AnalysisResult{
+				{Namespace: "shop", PodName: "api-1", ContainerName: "app", Suppressed: []tools.Failure{suppressed}},
+				analysis("api-2", failure("a", 1)),
+			},
+			want: []Incident{
+				{Fingerprint: "c", Pods: []string{"api-1"}, Failure: tools.Failure{Count: 1, SuppressedBy: "silence s1"}},
+				{Fingerprint: "a", Pods: []string{"api-2"}, Failure: tools.Failure{Count: 1}},
+			},
+		},
+		{
+			name:    "no failures",
+			results: []*AnalysisResult{analysis("api-1"), nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incidents := GroupIncidents(tt.results)
+			if len(incidents) != len(tt.want) {
+				t.Fatalf("GroupIncidents() = %d incidents, want %d", len(incidents), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := incidents[i]
+				if got.Fingerprint != want.Fingerprint || !slices.Equal(got.Pods, want.Pods) ||
+					got.Failure.Count != want.Failure.Count || got.Failure.SuppressedBy != want.Failure.SuppressedBy {
+					t.Errorf("incident %d = %s %v x%d %q, want %s %v x%d %q", i,
+						got.Fingerprint, got.Pods, got.Failure.Count, got.Failure.SuppressedBy,
+						want.Fingerprint, want.Pods, want.Failure.Count, want.Failure.SuppressedBy)
+				}
+				if got.Suppressed() != (want.Failure.SuppressedBy != "") {
+					t.Errorf("incident %d Suppressed() = %v", i, got.Suppressed())
+				}
+			}
+		})
+	}
+}
+
+func TestIncidentTracker(t *testing.T) {
+	type step struct {
+		at         time.Duration
+		result     *AnalysisResult
+		wantFresh  []string // fingerprints
+		wantJoined []string // pods of the joined incidents, comma-separated
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "replica joins the open incident",
+			steps: []step{
+				{0, analysis("api-1", failure("a", 1)), []string{"a"}, nil},
+				{time.Minute, analysis("api-2", failure("a", 1)), nil, []string{"api-1,api-2"}},
+				{2 * time.Minute, analysis("api-2", failure("a", 1)), nil, []string{"api-1,api-2"}},
+			},
+		},
+		{
+			name: "other failure opens another incident",
+			steps: []step{
+				{0, analysis("api-1", failure("a", 1)), []string{"a"}, nil},
+				{time.Minute, analysis("api-2", failure("a", 1), failure("b", 1)), []string{"b"}, []string{"api-1,api-2"}},
+			},
+		},
+		{
+			name: "incident closes after the window",
+			steps: []step{
+				{0, analysis("api-1", failure("a", 1)), []string{"a"}, nil},
+				{20 * time.Minute, analysis("api-2", failure("a", 1)), nil, []string{"api-1,api-2"}},
+				// The window counts from when the failure was last seen
+				{40 * time.Minute, analysis("api-3", failure("a", 1)), nil, []string{"api-1,api-2,api-3"}},
+				{71 * time.Minute, analysis("api-4", failure("a", 1)), []string{"a"}, nil},
+			},
+		},
+		{
+			name: "healthy analysis",
+			steps: []step{
+				{0, analysis("api-1"), nil, nil},
+			},
+		},
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewIncidentTracker(30 * time.Minute)
+			for i, step := range tt.steps {
+				now := start.Add(step.at)
+				fresh, joined := tracker.Split(step.result, now)
+				var gotFresh, gotJoined []string
+				for _, incident := range fresh {
+					gotFresh = append(gotFresh, incident.Fingerprint)
+				}
+				for _, incident := range joined {
+					gotJoined = append(gotJoined, strings.Join(incident.Pods, ","))
+				}
+				if !slices.Equal(gotFresh, step.wantFresh) || !slices.Equal(gotJoined, step.wantJoined) {
+					t.Errorf("step %d: Split() fresh = %v, joined = %v, want %v, %v", i, gotFresh, gotJoined, step.wantFresh, step.wantJoined)
+				}
+				tracker.Open(fresh, now)
+			}
+		})
+	}
+}
+
+func TestIncidentTrackerJoinedCopies(t *testing.T) {
+	tracker := NewIncidentTracker(time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fresh, _ := tracker.Split(analysis("api-1", failure("a", 1)), now)
+	fresh[0].Recommendation = "raise the memory limit"
+	tracker.Open(fresh, now)
+
+	_, joined := tracker.Split(analysis("api-2", failure("a", 1)), now)
+	if len(joined) != 1 || joined[0].Recommendation != "raise the memory limit" {
+		t.Fatalf("Split() joined = %v, want the open incident with its recommendation", joined)
+	}
+	joined[0].Pods[0] = "changed"
+	_, joined = tracker.Split(analysis("api-3", failure("a", 1)), now)
+	if got := strings.Join(joined[0].Pods, ","); got != "api-1,api-2,api-3" {
+		t.Errorf("open incident pods = %s, want api-1,api-2,api-3", got)
+	}
+}
@@ -5,37 +5,176 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/vasudevchavan/K8sLogmonitor/adk"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
 )
 
+// issueRepository is the repository searched for issues related to failures.
+const issueRepository = "vasudevchavan/K8sLogmonitor"
+
 type LogMonitorAgent struct {
 	*adk.BaseAgent
-	registry adk.ToolRegistry
+	registry        adk.ToolRegistry
+	rules           tools.RuleProvider
+	recommendations *recommendationCache
 }
 
-func NewLogMonitorAgent(registry adk.ToolRegistry) *LogMonitorAgent {
+// NewLogMonitorAgent creates the agent. rules, the detection engine behind
+// the failure_detection tool, reports the rules version of every analysis.
+// Recommendations are reused for failures with the same fingerprints for
+// recommendationTTL; zero disables the reuse.
+func NewLogMonitorAgent(registry adk.ToolRegistry, rules tools.RuleProvider, recommendationTTL time.Duration) *LogMonitorAgent {
 	agent := &LogMonitorAgent{
-		BaseAgent: adk.NewBaseAgent("log_monitor"),
-		registry:  registry,
+		BaseAgent:       adk.NewBaseAgent("log_monitor"),
+		registry:        registry,
+		rules:           rules,
+		recommendations: newRecommendationCache(recommendationTTL),
 	}
 	return agent
 }
 
+// AnalysisResult is the outcome of analyzing one container, or one object's
+// Warning events.
+type AnalysisResult struct {
+	Namespace      string
+	PodName        string
+	ContainerName  string
+	Kind           tools.ContainerKind
+	// Workload is the controller owning the pod, or the pod itself
+	Workload       tools.Workload
+	Failures       []tools.Failure
+	// Suppressed failures were hidden by a suppression or silence and are
+	// not part of Failures; each says which one hid it
+	Suppressed     []tools.Failure
+	// RulesVersion is the version of the failure rules the logs were
+	// matched with, also when nothing matched
+	RulesVersion   string
+	Recommendation string
+	// context describes the failures for the LLM, for a recommendation
+	// made after detection, and podContext the pod's part of it
+	context    string
+	podContext string
+}
+
+func (r *AnalysisResult) HasFailures() bool {
+	return r != nil && len(r.Failures) > 0
+}
+
+// String renders the result the way the CLI prints it.
+func (r *AnalysisResult) String() string {
+	var s string
+	switch {
+	case !r.HasFailures():
+		s = "No failures detected"
+	case r.Recommendation == "":
+		s = fmt.Sprintf("Failures detected: %v", tools.FailureMessages(r.Failures))
+	default:
+		s = fmt.Sprintf("Failures: %v\nRecommendation: %s", tools.FailureMessages(r.Failures), r.Recommendation)
+	}
+	if r.HasFailures() && r.RulesVersion != "" {
+		s += "\nRules version: " + r.RulesVersion
+	}
+	if len(r.Suppressed) > 0 {
+		s += "\nSuppressed:"
+		for _, failure := range r.Suppressed {
+			s += fmt.Sprintf("\n- %s [%s]", failure, failure.SuppressedBy)
+		}
+	}
+	return s
+}
+
 func (a *LogMonitorAgent) Execute(ctx context.Context, input string) (string, error) {
+	result, err := a.Analyze(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// Analyze runs the full pipeline for the container named by input
+// (namespace|pod_name|container_name[|container_kind]). The container's log
+// cursor only advances once the analysis succeeded, so a failed one reads
+// the same logs again when it is retried.
+func (a *LogMonitorAgent) Analyze(ctx context.Context, input string) (*AnalysisResult, error) {
+	result, err := a.Detect(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	
+	if result.HasFailures() {
+		result.Recommendation, err = a.recommend(ctx, result.PodName, result.Failures, result.context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.commitLogCursor(ctx, result)
+	return result, nil
+}
+
+// commitLogCursor advances the log cursor of the analyzed container past the
+// logs Detect read.
+func (a *LogMonitorAgent) commitLogCursor(ctx context.Context, result *AnalysisResult) {
+	k8sTool, exists := a.registry.GetTool("k8s_logs")
+	if !exists {
+		return
+	}
+	_, err := k8sTool.Execute(ctx, map[string]interface{}{
+		"namespace":      result.Namespace,
+		"pod_name":       result.PodName,
+		"container_name": result.ContainerName,
+		"commit":         true,
+	})
+	if err != nil {
+		log.Printf("Failed to advance log cursor for %s/%s: %v", result.PodName, result.ContainerName, err)
+	}
+}
+
+// Detect runs the pipeline for the container named by input up to the
+// recommendation: the failures are detected, suppressed and described with
+// their Kubernetes context. Scans recommend once per Incident instead.
+func (a *LogMonitorAgent) Detect(ctx context.Context, input string) (*AnalysisResult, error) {
 	parts := strings.Split(input, "|")
-	if len(parts) != 3 {
-		return "", fmt.Errorf("input format: namespace|pod_name|container_name")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, fmt.Errorf("input format: namespace|pod_name|container_name[|container_kind]")
 	}
 	
 	namespace, podName, containerName := parts[0], parts[1], parts[2]
 	
+	status := a.getContainerStatus(ctx, namespace, podName, containerName)
+	kind := tools.ContainerKindRegular
+	if len(parts) == 4 && parts[3] != "" {
+		kind = tools.ContainerKind(parts[3])
+	} else if status != nil {
+		kind = status.Kind
+	}
+	workload := statusWorkload(status, podName)
+	result := &AnalysisResult{Namespace: namespace, PodName: podName, ContainerName: containerName, Kind: kind, Workload: workload,
+		RulesVersion: a.rules.Rules().Version()}
+	
+	// Main containers stuck in PodInitializing are blocked by an init
+	// container; the init container's own analysis reports the root cause
+	if status != nil && tools.IsBlockedByInitContainers(*status) {
+		log.Printf("DEBUG: %s/%s is waiting on init containers, skipping", podName, containerName)
+		return result, nil
+	}
+	
+	var statusFailures []tools.StatusFailure
+	if status != nil {
+		statusFailures = status.Failures
+	}
+	
 	// Get K8s logs tool
 	k8sTool, exists := a.registry.GetTool("k8s_logs")
 	if !exists {
-		return "", fmt.Errorf("k8s_logs tool not found")
+		return nil, fmt.Errorf("k8s_logs tool not found")
 	}
 	
+	// A restarted container's current log is often empty or just says it is
+	// waiting to start; the crash output lives in the previous instance
+	previousLogs := a.fetchPreviousLogs(ctx, k8sTool, namespace, podName, containerName, status)
+	
 	// Fetch logs
 	logResult, err := k8sTool.Execute(ctx, map[string]interface{}{
 		"namespace":      namespace,
@@ -46,84 +185,256 @@ func (a *LogMonitorAgent) Execute(ctx context.Context, input string) (string, er
 	
 	var logs string
 	if err != nil {
-		// If error contains startup/runtime issues, treat as failure context
-		errorStr := strings.ToLower(err.Error())
-		if strings.Contains(errorStr, "waiting to start") || strings.Contains(errorStr, "pull image") ||
-			strings.Contains(errorStr, "crashloopbackoff") || strings.Contains(errorStr, "oomkilled") ||
-			strings.Contains(errorStr, "evicted") || strings.Contains(errorStr, "pending") ||
-			strings.Contains(errorStr, "probe failed") || strings.Contains(errorStr, "mount") ||
-			strings.Contains(errorStr, "volume") || strings.Contains(errorStr, "secret") ||
-			strings.Contains(errorStr, "configmap") {
-			logs = fmt.Sprintf("Container error: %v", err)
-		} else {
-			return "", fmt.Errorf("failed to fetch logs: %w", err)
+		// A container that never started has no logs; its status says why
+		if len(statusFailures) == 0 && previousLogs == "" {
+			return nil, fmt.Errorf("failed to fetch logs: %w", err)
 		}
+		log.Printf("DEBUG: No current logs for %s/%s: %v", podName, containerName, err)
 	} else {
 		var ok bool
 		logs, ok = logResult.(string)
 		if !ok {
-			return "", fmt.Errorf("unexpected log format")
+			return nil, fmt.Errorf("unexpected log format")
 		}
-		if logs == "" {
-			return "No failures detected", nil
+	}
+	
+	// Failures reported by the pod status come first; they are typed and do
+	// not depend on what the application logged
+	var failures []tools.Failure
+	for _, failure := range statusFailures {
+		failures = append(failures, failure.Failure())
+	}
+	
+	if logs != "" || previousLogs != "" {
+		failureTool, exists := a.registry.GetTool("failure_detection")
+		if !exists {
+			return nil, fmt.Errorf("failure_detection tool not found")
 		}
+		
+		// The previous instance's logs are matched apart, so line numbers
+		// stay within each instance's logs
+		failureResult, err := failureTool.Execute(ctx, map[string]interface{}{
+			"logs":          logs,
+			"previous_logs": previousLogs,
+			"container_key": tools.CursorKey(namespace, podName, containerName),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect failures: %w", err)
+		}
+		
+		logFailures, ok := failureResult.([]tools.Failure)
+		if !ok {
+			return nil, fmt.Errorf("unexpected failure format")
+		}
+		failures = append(failures, logFailures...)
 	}
 	
-	// Detect failures
-	failureTool, exists := a.registry.GetTool("failure_detection")
-	if !exists {
-		return "", fmt.Errorf("failure_detection tool not found")
+	healthy := len(failures) == 0
+	if previousLogs != "" {
+		anomalies := a.detectAnomalies(ctx, namespace, workload, previousLogs, healthy)
+		for i := range anomalies {
+			anomalies[i].Previous = true
+		}
+		failures = append(failures, anomalies...)
+	}
+	if logs != "" {
+		failures = append(failures, a.detectAnomalies(ctx, namespace, workload, logs, healthy)...)
 	}
+	tools.FingerprintFailures(failures, namespace, workload, containerName, podName)
+	failures, result.Suppressed = a.suppress(ctx, namespace, workload, failures)
 	
-	failureResult, err := failureTool.Execute(ctx, map[string]interface{}{
-		"logs": logs,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to detect failures: %w", err)
+	log.Printf("DEBUG: Detected %d failures for %s/%s: %v", len(failures), podName, containerName, tools.FailureMessages(failures))
+	
+	result.Failures = failures
+	if len(failures) == 0 {
+		return result, nil
 	}
 	
-	failures, ok := failureResult.([]string)
-	if !ok {
-		return "", fmt.Errorf("unexpected failure format")
+	// Get comprehensive K8s context; collected logs have no cluster to ask
+	var k8sContext interface{}
+	if contextTool, exists := a.registry.GetTool("k8s_context"); exists {
+		k8sContext, err = contextTool.Execute(ctx, map[string]interface{}{
+			"namespace": namespace,
+			"pod_name":  podName,
+		})
+		if err != nil {
+			log.Printf("Failed to get K8s context: %v", err)
+		}
 	}
+	k8sContext, exits := splitTerminations(k8sContext)
 	
-	log.Printf("DEBUG: Detected %d failures for %s/%s: %v", len(failures), podName, containerName, failures)
+	result.podContext = fmt.Sprintf("%sK8s Context: %v\n", exits, k8sContext)
+	if kind == tools.ContainerKindInit {
+		result.podContext += "Init container failure: init containers must complete before the main containers start, " +
+			"so this failure is the root cause for the pod's main containers being stuck in PodInitializing.\n"
+	}
+	result.context = failureContext(podName, namespace, containerName, kind, failures, result.podContext)
+	return result, nil
+}
+
+// failureContext describes failures of a container for the LLM. Repeated
+// failures are aggregated and carry their surrounding lines, so the raw logs
+// are not sent again.
+func failureContext(podName, namespace, containerName string, kind tools.ContainerKind, failures []tools.Failure, podContext string) string {
+	return fmt.Sprintf(`Pod: %s
+Namespace: %s
+Container: %s (%s container)
+Failures:
+%s%s`,
+		podName, namespace, containerName, kind, tools.DescribeFailures(failures), podContext)
+}
+
+// AnalyzeEvents runs the recommendation pipeline for Warning events of an
+// object, for failures that never produce a log line such as a pod that
+// cannot be scheduled or mount its volumes.
+func (a *LogMonitorAgent) AnalyzeEvents(ctx context.Context, namespace, kind, name string, failures []tools.Failure) (*AnalysisResult, error) {
+	// A ReplicaSet's or Job's events count towards its Deployment or CronJob
+	workload := tools.ControllerWorkload(kind, name)
+	podName := ""
+	if kind == "Pod" {
+		// Resolved like for log analyses, so a pod's events and logs share
+		// its workload's fingerprints and suppressions
+		workload = statusWorkload(a.getContainerStatus(ctx, namespace, name, ""), name)
+		podName = name
+	}
+	tools.FingerprintFailures(failures, namespace, workload, "", podName)
+	failures, suppressed := a.suppress(ctx, namespace, workload, failures)
 	
+	result := &AnalysisResult{Namespace: namespace, PodName: podName, Workload: workload, Failures: failures, Suppressed: suppressed,
+		RulesVersion: a.rules.Rules().Version()}
 	if len(failures) == 0 {
-		return "No failures detected", nil
+		return result, nil
 	}
 	
-	// Get comprehensive K8s context
-	contextTool, exists := a.registry.GetTool("k8s_context")
+	contextStr := fmt.Sprintf(`%s: %s
+Namespace: %s
+Warning events:
+%s`,
+		kind, name, namespace, tools.DescribeFailures(failures))
+	
+	if kind == "Pod" {
+		if contextTool, exists := a.registry.GetTool("k8s_context"); exists {
+			k8sContext, err := contextTool.Execute(ctx, map[string]interface{}{
+				"namespace": namespace,
+				"pod_name":  name,
+			})
+			if err != nil {
+				log.Printf("Failed to get K8s context: %v", err)
+			}
+			k8sContext, exits := splitTerminations(k8sContext)
+			contextStr += fmt.Sprintf("%sK8s Context: %v\n", exits, k8sContext)
+		}
+	}
+	
+	recommendation, err := a.recommend(ctx, name, failures, contextStr)
+	if err != nil {
+		return nil, err
+	}
+	result.Recommendation = recommendation
+	return result, nil
+}
+
+// detectAnomalies returns the log lines the workload does not usually log.
+// healthy logs, those without other failures, may join its baseline.
+func (a *LogMonitorAgent) detectAnomalies(ctx context.Context, namespace string, workload tools.Workload, logs string, healthy bool) []tools.Failure {
+	anomalyTool, exists := a.registry.GetTool("anomaly_detection")
 	if !exists {
-		return "", fmt.Errorf("k8s_context tool not found")
+		return nil
 	}
 	
-	k8sContext, err := contextTool.Execute(ctx, map[string]interface{}{
+	anomalyResult, err := anomalyTool.Execute(ctx, map[string]interface{}{
 		"namespace": namespace,
-		"pod_name":  podName,
+		"workload":  workload,
+		"logs":      logs,
+		"healthy":   healthy,
 	})
 	if err != nil {
-		log.Printf("Failed to get K8s context: %v", err)
+		log.Printf("Failed to detect log anomalies: %v", err)
+	}
+	anomalies, _ := anomalyResult.([]tools.Failure)
+	return anomalies
+}
+
+// splitTerminations takes the exit code interpretations out of the pod
+// context and renders them as their own section, which the fallback
+// recommendations read.
+func splitTerminations(k8sContext interface{}) (interface{}, string) {
+	podContext, ok := k8sContext.(tools.PodContext)
+	if !ok || len(podContext.Terminations) == 0 {
+		return k8sContext, ""
+	}
+	exits := tools.DescribeTerminations(podContext.Terminations)
+	podContext.Terminations = nil
+	return podContext, exits
+}
+
+// suppress hides the failures matched by a configured suppression or an
+// active silence. Without the failure_suppression tool every failure is
+// kept.
+func (a *LogMonitorAgent) suppress(ctx context.Context, namespace string, workload tools.Workload, failures []tools.Failure) ([]tools.Failure, []tools.Failure) {
+	suppressionTool, exists := a.registry.GetTool("failure_suppression")
+	if !exists || len(failures) == 0 {
+		return failures, nil
 	}
 	
+	suppressionResult, err := suppressionTool.Execute(ctx, map[string]interface{}{
+		"namespace": namespace,
+		"workload":  workload,
+		"failures":  failures,
+	})
+	if err != nil {
+		log.Printf("Failed to apply suppressions: %v", err)
+		return failures, nil
+	}
+	
+	result, ok := suppressionResult.(tools.SuppressionResult)
+	if !ok {
+		return failures, nil
+	}
+	for _, failure := range result.Suppressed {
+		log.Printf("DEBUG: Suppressed %q in %s (%s): %s", failure.Message, namespace, workload, failure.SuppressedBy)
+	}
+	return result.Kept, result.Suppressed
+}
+
+// recommend searches related GitHub issues and asks the LLM for a
+// recommendation given the failures and the context gathered so far. It
+// returns an empty recommendation when the LLM call fails. The same
+// failures, by fingerprint, get the cached recommendation, or share the one
+// being made.
+func (a *LogMonitorAgent) recommend(ctx context.Context, name string, failures []tools.Failure, contextStr string) (string, error) {
+	fingerprints := tools.FingerprintSet(failures)
+	return a.recommendations.do(strings.Join(fingerprints, ","), func() (string, error) {
+		return a.makeRecommendation(ctx, name, failures, fingerprints, contextStr)
+	})
+}
+
+// makeRecommendation does the GitHub searches and the LLM call of
+// recommend.
+func (a *LogMonitorAgent) makeRecommendation(ctx context.Context, name string, failures []tools.Failure, fingerprints []string, contextStr string) (string, error) {
 	// Search GitHub issues using GitHub agent
 	githubAgent := NewGitHubAgent(a.registry)
 	githubIssues := "No related issues found."
 	
-	// Search based on pod name and failure type
-	query := podName
-	if strings.Contains(strings.Join(failures, " "), "oom") {
-		query += " oom memory"
-	} else if strings.Contains(strings.Join(failures, " "), "crash") {
-		query += " crash"
-	} else {
-		query += " image"
+	// Issues that mention a failure's fingerprint were filed for it
+	linkedIssues := ""
+	if len(fingerprints) > 0 {
+		var err error
+		linkedIssues, err = githubAgent.FindLinked(ctx, fingerprints, issueRepository)
+		if err != nil {
+			log.Printf("DEBUG: GitHub linked issues error: %v", err)
+		}
+	}
+	
+	// Search based on what failed rather than the object's name
+	query := searchQuery(failures)
+	if query == "" {
+		query = name
 	}
 	log.Printf("DEBUG: Searching GitHub for: %s", query)
 	
 	// Use GitHub agent to search issues
-	githubInput := fmt.Sprintf("%s|vasudevchavan/K8sLogmonitor", query)
+	githubInput := fmt.Sprintf("%s|%s", query, issueRepository)
 	githubResult, err := githubAgent.Execute(ctx, githubInput)
 	if err != nil {
 		log.Printf("DEBUG: GitHub agent error: %v", err)
@@ -141,13 +452,8 @@ func (a *LogMonitorAgent) Execute(ctx context.Context, input string) (string, er
 		return "", fmt.Errorf("llm_recommendation tool not found")
 	}
 	
-	contextStr := fmt.Sprintf(`Pod: %s
-Namespace: %s
-Failures: %s
-Logs: %s
-K8s Context: %v
-%s`,
-		podName, namespace, strings.Join(failures, ", "), logs, k8sContext, githubIssues)
+	contextStr += remediationHints(failures)
+	contextStr += linkedIssues + githubIssues
 	
 	log.Printf("DEBUG: Calling LLM with enhanced context including GitHub issues")
 	
@@ -156,10 +462,117 @@ K8s Context: %v
 	})
 	if err != nil {
 		log.Printf("Failed to generate recommendation: %v", err)
-		return fmt.Sprintf("Failures detected: %v", failures), nil
+		return "", nil
 	}
 	
 	log.Printf("DEBUG: LLM recommendation: %s", recommendation)
 	
-	return fmt.Sprintf("Failures: %v\nRecommendation: %s", failures, recommendation), nil
+	text, _ := recommendation.(string)
+	return text, nil
+}
+
+// statusWorkload returns the workload owning the pod according to its
+// status, or the one guessed from the pod's name without a status.
+func statusWorkload(status *tools.ContainerStatusResult, podName string) tools.Workload {
+	if status != nil {
+		return status.Workload
+	}
+	return tools.WorkloadFromPodName(podName)
+}
+
+// getContainerStatus returns the container's status, or nil when it cannot
+// be determined. Without a containerName the status only has the pod's
+// workload.
+func (a *LogMonitorAgent) getContainerStatus(ctx context.Context, namespace, podName, containerName string) *tools.ContainerStatusResult {
+	statusTool, exists := a.registry.GetTool("k8s_container_status")
+	if !exists {
+		return nil
+	}
+	
+	statusResult, err := statusTool.Execute(ctx, map[string]interface{}{
+		"namespace":      namespace,
+		"pod_name":       podName,
+		"container_name": containerName,
+	})
+	if err != nil {
+		log.Printf("DEBUG: Failed to get container status for %s/%s: %v", podName, containerName, err)
+		return nil
+	}
+	
+	status, ok := statusResult.(tools.ContainerStatusResult)
+	if !ok {
+		return nil
+	}
+	return &status
+}
+
+// fetchPreviousLogs returns the logs of the container's last terminated
+// instance when it has restarted or has a recorded termination, and an empty
+// string otherwise. Without a status, e.g. for collected logs, the previous
+// instance's logs are read when the source has them.
+func (a *LogMonitorAgent) fetchPreviousLogs(ctx context.Context, k8sTool adk.Tool, namespace, podName, containerName string, status *tools.ContainerStatusResult) string {
+	if status != nil && status.Status.RestartCount == 0 && status.Status.LastTerminationState.Terminated == nil {
+		return ""
+	}
+	
+	previousResult, err := k8sTool.Execute(ctx, map[string]interface{}{
+		"namespace":      namespace,
+		"pod_name":       podName,
+		"container_name": containerName,
+		"tail_lines":     int64(100),
+		"previous":       true,
+	})
+	if err != nil {
+		log.Printf("DEBUG: Failed to fetch previous logs for %s/%s: %v", podName, containerName, err)
+		return ""
+	}
+	
+	previousLogs, _ := previousResult.(string)
+	log.Printf("DEBUG: Fetched previous instance logs for %s/%s", podName, containerName)
+	return previousLogs
+}
+
+// searchQuery builds the issue search query from the most severe failure
+// with an exception, using its type and message, or else from the most
+// severe failure's category.
+func searchQuery(failures []tools.Failure) string {
+	var top, topException *tools.Failure
+	for i := range failures {
+		failure := &failures[i]
+		if top == nil || failure.Severity.Rank() > top.Severity.Rank() {
+			top = failure
+		}
+		if failure.Exception != nil && (topException == nil || failure.Severity.Rank() > topException.Severity.Rank()) {
+			topException = failure
+		}
+	}
+	query := ""
+	switch {
+	case topException != nil:
+		query = topException.Exception.SearchTerms()
+	case top != nil && top.Category != "":
+		query = top.Category
+	case top != nil:
+		query = top.RuleID
+	}
+	// The GitHub agent's input separates the query from the repository by "|"
+	return strings.ReplaceAll(query, "|", " ")
+}
+
+// remediationHints lists the remediation of each failure rule that matched,
+// once per rule.
+func remediationHints(failures []tools.Failure) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, failure := range failures {
+		if failure.Remediation == "" || seen[failure.RuleID] {
+			continue
+		}
+		seen[failure.RuleID] = true
+		fmt.Fprintf(&b, "- %s (%s, %s): %s\n", failure.RuleID, failure.Category, failure.Severity, failure.Remediation)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "Remediation hints:\n" + b.String()
 }
\ No newline at end of file
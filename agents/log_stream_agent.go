@@ -0,0 +1,308 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/adk"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// FailureHandler is called when a followed log stream produces failures.
+type FailureHandler func(namespace, podName, containerName string, kind tools.ContainerKind, failures []tools.Failure)
+
+// LogStreamAgent follows the logs of every running container and feeds each
+// line through failure detection as it arrives. Streams are bounded by a fixed
+// number of slots and are reopened when the informer reports the container
+// running again after a restart.
+type LogStreamAgent struct {
+	client    kubernetes.Interface
+	registry  adk.ToolRegistry
+	filter    *tools.ScanFilter
+	informer  cache.SharedIndexInformer
+	onFailure FailureHandler
+	cooldown  time.Duration
+	slots     chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	streams    map[string]context.CancelFunc
+	lastSeen   map[string]time.Time // key: namespace/pod/container, end of the last stream
+	lastNotify map[string]time.Time // key: failure fingerprint
+}
+
+func NewLogStreamAgent(client kubernetes.Interface, factory informers.SharedInformerFactory, registry adk.ToolRegistry, filter *tools.ScanFilter, maxStreams int, cooldown time.Duration, onFailure FailureHandler) *LogStreamAgent {
+	ctx, cancel := context.WithCancel(context.Background())
+	agent := &LogStreamAgent{
+		client:     client,
+		registry:   registry,
+		filter:     filter,
+		informer:   factory.Core().V1().Pods().Informer(),
+		onFailure:  onFailure,
+		cooldown:   cooldown,
+		slots:      make(chan struct{}, maxStreams),
+		ctx:        ctx,
+		cancel:     cancel,
+		streams:    make(map[string]context.CancelFunc),
+		lastSeen:   make(map[string]time.Time),
+		lastNotify: make(map[string]time.Time),
+	}
+
+	agent.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				agent.syncPod(pod)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if pod, ok := newObj.(*corev1.Pod); ok {
+				agent.syncPod(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				agent.stopPod(pod)
+			}
+		},
+	})
+
+	return agent
+}
+
+// Run blocks until ctx is cancelled and then closes every open stream. The
+// informer factory is started by whichever agent owns it.
+func (a *LogStreamAgent) Run(ctx context.Context) {
+	<-ctx.Done()
+	a.cancel()
+}
+
+func (a *LogStreamAgent) syncPod(pod *corev1.Pod) {
+	for _, result := range a.filter.ContainerStatuses(pod) {
+		if result.Status.State.Running != nil {
+			a.startStream(pod.Namespace, pod.Name, result.Status.Name, result.Kind)
+		}
+	}
+}
+
+func (a *LogStreamAgent) stopPod(pod *corev1.Pod) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prefix := fmt.Sprintf("%s/%s/", pod.Namespace, pod.Name)
+	for key, cancel := range a.streams {
+		if strings.HasPrefix(key, prefix) {
+			cancel()
+			delete(a.streams, key)
+		}
+	}
+	for key := range a.lastSeen {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.lastSeen, key)
+		}
+	}
+}
+
+func (a *LogStreamAgent) startStream(namespace, podName, containerName string, kind tools.ContainerKind) {
+	key := fmt.Sprintf("%s/%s/%s", namespace, podName, containerName)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, running := a.streams[key]; running {
+		return
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+	default:
+		log.Printf("Log stream pool is full, not following %s", key)
+		return
+	}
+
+	// Containers already running when the agent starts are followed from now
+	// on; after a restart the stream resumes from where the last one ended so
+	// the new instance's first lines are not lost.
+	since, seen := a.lastSeen[key]
+	if !seen {
+		since = time.Now()
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.streams[key] = cancel
+	go a.follow(ctx, key, namespace, podName, containerName, kind, since)
+}
+
+func (a *LogStreamAgent) follow(ctx context.Context, key, namespace, podName, containerName string, kind tools.ContainerKind, since time.Time) {
+	defer func() {
+		<-a.slots
+		a.mu.Lock()
+		if ctx.Err() == nil {
+			a.lastSeen[key] = time.Now()
+		}
+		delete(a.streams, key)
+		a.mu.Unlock()
+	}()
+
+	// Lines are grouped into multiline events so a stack trace reaches
+	// detection as one piece
+	events := &streamEvents{
+		assembler: tools.NewMultilineAssembler(),
+		emit: func(event tools.LogEvent) {
+			a.handleEvent(ctx, namespace, podName, containerName, kind, event)
+		},
+	}
+	defer events.flush()
+
+	backoff := newStreamBackoff()
+	for {
+		sinceTime := metav1.NewTime(since)
+		delivered := false
+		err := tools.StreamPodLogs(ctx, a.client, namespace, podName, containerName, &sinceTime, func(line string) {
+			since = time.Now()
+			delivered = true
+			events.add(line)
+		})
+		if ctx.Err() != nil || err == nil {
+			// A clean end of stream means the container stopped; the informer
+			// reopens it once the container is running again.
+			return
+		}
+		// Only consecutive failed connections count toward giving up; a
+		// stream that delivered lines was healthy until it broke
+		if delivered {
+			backoff = newStreamBackoff()
+		}
+		if backoff.Steps <= 1 {
+			log.Printf("Giving up following %s: %v", key, err)
+			return
+		}
+		log.Printf("Log stream for %s interrupted, reconnecting: %v", key, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// newStreamBackoff returns the reconnect backoff of a log stream.
+func newStreamBackoff() wait.Backoff {
+	return wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: 5, Cap: 30 * time.Second}
+}
+
+func (a *LogStreamAgent) handleEvent(ctx context.Context, namespace, podName, containerName string, kind tools.ContainerKind, event tools.LogEvent) {
+	failureTool, exists := a.registry.GetTool("failure_detection")
+	if !exists {
+		return
+	}
+
+	failureResult, err := failureTool.Execute(ctx, map[string]interface{}{
+		"logs":          event.Text(),
+		"container_key": tools.CursorKey(namespace, podName, containerName),
+	})
+	if err != nil {
+		log.Printf("Failed to detect failures in stream for %s/%s: %v", podName, containerName, err)
+		return
+	}
+
+	failures, ok := failureResult.([]tools.Failure)
+	if !ok || len(failures) == 0 {
+		return
+	}
+	// The assembler already stripped the timestamp prefix off the event, and
+	// line numbers count from the start of the stream
+	for i := range failures {
+		failures[i].LineNumber += event.StartLine - 1
+		if failures[i].FirstSeen.IsZero() {
+			failures[i].FirstSeen, failures[i].LastSeen = event.Timestamp, event.Timestamp
+		}
+	}
+
+	workload := tools.WorkloadFromPodName(podName)
+	if obj, exists, _ := a.informer.GetStore().GetByKey(namespace + "/" + podName); exists {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			workload = tools.PodWorkload(pod)
+		}
+	}
+	tools.FingerprintFailures(failures, namespace, workload, containerName, podName)
+
+	log.Printf("DEBUG: Stream detected %d failures for %s/%s: %v", len(failures), podName, containerName, tools.FailureMessages(failures))
+
+	// A failure is notified once per cooldown, however many lines or
+	// replicas of the workload log it
+	now := time.Now()
+	fresh := false
+	a.mu.Lock()
+	for fingerprint, notified := range a.lastNotify {
+		if now.Sub(notified) >= a.cooldown {
+			delete(a.lastNotify, fingerprint)
+		}
+	}
+	for _, failure := range failures {
+		if _, notified := a.lastNotify[failure.Fingerprint]; !notified {
+			a.lastNotify[failure.Fingerprint] = now
+			fresh = true
+		}
+	}
+	a.mu.Unlock()
+	if !fresh {
+		return
+	}
+
+	if a.onFailure != nil {
+		a.onFailure(namespace, podName, containerName, kind, failures)
+	}
+}
+
+// eventIdleFlush is how long a followed stream may stay quiet before a
+// pending multiline event is considered complete.
+const eventIdleFlush = 2 * time.Second
+
+// streamEvents assembles followed lines into multiline events. A trailing
+// event is flushed once the stream goes idle, since no next line will close it.
+type streamEvents struct {
+	mu        sync.Mutex
+	assembler *tools.MultilineAssembler
+	idle      *time.Timer
+	emit      func(event tools.LogEvent)
+}
+
+func (s *streamEvents) add(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	for _, event := range s.assembler.Add(line) {
+		s.emit(event)
+	}
+	s.idle = time.AfterFunc(eventIdleFlush, s.flush)
+}
+
+func (s *streamEvents) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	for _, event := range s.assembler.Flush() {
+		s.emit(event)
+	}
+}
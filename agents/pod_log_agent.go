@@ -8,21 +8,27 @@ import (
 	"time"
 
 	"github.com/vasudevchavan/K8sLogmonitor/tools"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type PodLogAgent struct {
-	client         *kubernetes.Clientset
-	tailLines      int64
-	lastTimestamps map[string]time.Time // key: namespace/pod/container
+	source    tools.LogSource
+	tailLines int64
+	filter    *tools.ScanFilter
+	cursors   tools.CursorStore // key: namespace/pod/container
 }
 
-func NewPodLogAgent(client *kubernetes.Clientset, tailLines int64) *PodLogAgent {
+// NewPodLogAgent creates a PodLogAgent that reads logs from source and resumes
+// each container from its cursor. A nil store keeps cursors in memory only; a nil filter fetches every
+// container.
+func NewPodLogAgent(source tools.LogSource, tailLines int64, filter *tools.ScanFilter, cursors tools.CursorStore) *PodLogAgent {
+	if cursors == nil {
+		cursors = tools.NewMemoryCursorStore()
+	}
 	return &PodLogAgent{
-		client:         client,
-		tailLines:      tailLines,
-		lastTimestamps: make(map[string]time.Time),
+		source:    source,
+		tailLines: tailLines,
+		filter:    filter,
+		cursors:   cursors,
 	}
 }
 
@@ -31,33 +37,49 @@ func (p *PodLogAgent) FetchLogs(namespace string) (map[string]string, error) {
 		return nil, fmt.Errorf("namespace cannot be empty")
 	}
 
-	pods, err := p.client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if !p.filter.MatchNamespace(namespace) {
+		return map[string]string{}, nil
+	}
+
+	containers, err := p.source.ListContainers(context.Background(), namespace, p.filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+		return nil, fmt.Errorf("failed to list containers in namespace %s: %w", namespace, err)
 	}
 
 	podLogs := make(map[string]string)
+	livePods := make(map[string]bool)
 
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			key := fmt.Sprintf("%s/%s/%s", namespace, pod.Name, container.Name)
-			sinceTime := metav1.NewTime(p.lastTimestamps[key])
-			logContent, err := tools.GetPodLogsSince(p.client, namespace, pod.Name, container.Name, p.tailLines, &sinceTime)
-			if err != nil {
-				// Check if error indicates container startup issues
-				if strings.Contains(err.Error(), "waiting to start") || strings.Contains(err.Error(), "pull image") {
-					podLogs[key] = fmt.Sprintf("Container startup error: %v", err)
-					p.lastTimestamps[key] = time.Now()
-				} else {
-					log.Printf("Error fetching logs for %s: %v", key, err)
+	for _, container := range containers {
+		livePods[container.PodName] = true
+		key := tools.CursorKey(namespace, container.PodName, container.ContainerName)
+		logContent, fetchedAt, err := tools.GetPodLogsSince(context.Background(), p.source, p.cursors, namespace, container.PodName, container.ContainerName, p.tailLines, false)
+		if err != nil {
+			// Check if error indicates container startup issues
+			if strings.Contains(err.Error(), "waiting to start") || strings.Contains(err.Error(), "pull image") {
+				podLogs[key] = fmt.Sprintf("Container startup error: %v", err)
+				if err := p.cursors.Set(key, time.Now()); err != nil {
+					log.Printf("Error advancing log cursor for %s: %v", key, err)
 				}
-				continue
-			}
-			if logContent != "" {
-				podLogs[key] = logContent
-				p.lastTimestamps[key] = time.Now()
+			} else {
+				log.Printf("Error fetching logs for %s: %v", key, err)
 			}
+			continue
+		}
+		if err := p.cursors.Set(key, fetchedAt); err != nil {
+			log.Printf("Error advancing log cursor for %s: %v", key, err)
 		}
+		if logContent != "" {
+			podLogs[key] = logContent
+		}
+	}
+
+	// Drop cursors of pods that no longer exist so the store does not grow
+	// with every rollout
+	if err := p.cursors.Prune(namespace, livePods); err != nil {
+		log.Printf("Error pruning log cursors for namespace %s: %v", namespace, err)
+	}
+	if err := p.cursors.Flush(); err != nil {
+		log.Printf("Error saving log cursors: %v", err)
 	}
 	return podLogs, nil
 }
@@ -0,0 +1,266 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+	corev1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// cursorFlushInterval is how often the log cursors advanced by analyses are
+// saved.
+const cursorFlushInterval = 30 * time.Second
+
+// PodWatchAgent runs the LogMonitorAgent from pod informer events. Analysis is
+// only queued when a container status transitions (new restart, Waiting reason
+// change, termination), so unchanged pods never hit the API server.
+type PodWatchAgent struct {
+	monitor    *LogMonitorAgent
+	incidents  *IncidentTracker
+	filter     *tools.ScanFilter
+	cursors    tools.CursorStore
+	factory    informers.SharedInformerFactory
+	informer   cache.SharedIndexInformer
+	queue      workqueue.TypedRateLimitingInterface[string]
+	maxRetries int
+}
+
+// NewPodWatchAgent creates a PodWatchAgent that only analyzes containers
+// matched by filter. When cursors is not nil, they are saved periodically and
+// the cursors of deleted pods are garbage-collected. Failures are reported as
+// incidents that stay open for incidentWindow after they were last seen, so
+// the replicas of a workload failing the same way are reported and
+// recommended on once.
+func NewPodWatchAgent(factory informers.SharedInformerFactory, monitor *LogMonitorAgent, filter *tools.ScanFilter, cursors tools.CursorStore, maxRetries int, incidentWindow time.Duration) *PodWatchAgent {
+	agent := &PodWatchAgent{
+		monitor:   monitor,
+		incidents: NewIncidentTracker(incidentWindow),
+		filter:    filter,
+		cursors:   cursors,
+		factory:   factory,
+		informer:  factory.Core().V1().Pods().Informer(),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "pod_watch"},
+		),
+		maxRetries: maxRetries,
+	}
+
+	agent.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			for _, result := range agent.filter.ContainerStatuses(pod) {
+				if needsAnalysis(result.Status) {
+					agent.Enqueue(pod.Namespace, pod.Name, result.Status.Name, result.Kind)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			newPod, ok := newObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			// A resync redelivers unchanged pods; containers that are still
+			// failing are analyzed again, which also retries analyses that
+			// were given up on
+			if oldPod.ResourceVersion == newPod.ResourceVersion {
+				for _, result := range agent.filter.ContainerStatuses(newPod) {
+					if isFailing(result.Status) {
+						agent.Enqueue(newPod.Namespace, newPod.Name, result.Status.Name, result.Kind)
+					}
+				}
+				return
+			}
+			previous := make(map[string]corev1.ContainerStatus)
+			for _, result := range tools.PodContainerStatuses(oldPod) {
+				previous[result.Status.Name] = result.Status
+			}
+			for _, result := range agent.filter.ContainerStatuses(newPod) {
+				if statusTransitioned(previous[result.Status.Name], result.Status) {
+					agent.Enqueue(newPod.Namespace, newPod.Name, result.Status.Name, result.Kind)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				agent.pruneCursors(pod.Namespace)
+			}
+		},
+	})
+
+	return agent
+}
+
+// Enqueue schedules a container for analysis. Keys already waiting in the
+// queue are collapsed into a single run.
+func (a *PodWatchAgent) Enqueue(namespace, podName, containerName string, kind tools.ContainerKind) {
+	a.queue.Add(strings.Join([]string{namespace, podName, containerName, string(kind)}, "|"))
+}
+
+// Run starts the informers and blocks until ctx is cancelled.
+func (a *PodWatchAgent) Run(ctx context.Context, workers int) error {
+	defer utilruntime.HandleCrash()
+	defer a.queue.ShutDown()
+
+	a.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), a.informer.HasSynced) {
+		return fmt.Errorf("failed to sync pod informer cache")
+	}
+
+	log.Printf("Pod watch started with %d workers", workers)
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, a.runWorker, time.Second)
+	}
+	// Analyses advance the cursors in memory; they are saved on a timer
+	// rather than once per container
+	go wait.UntilWithContext(ctx, func(context.Context) { a.flushCursors() }, cursorFlushInterval)
+
+	<-ctx.Done()
+	a.flushCursors()
+	return nil
+}
+
+// flushCursors saves the log cursors advanced since the last flush.
+func (a *PodWatchAgent) flushCursors() {
+	if a.cursors == nil {
+		return
+	}
+	if err := a.cursors.Flush(); err != nil {
+		log.Printf("Failed to save log cursors: %v", err)
+	}
+}
+
+func (a *PodWatchAgent) runWorker(ctx context.Context) {
+	for a.processNextItem(ctx) {
+	}
+}
+
+func (a *PodWatchAgent) processNextItem(ctx context.Context) bool {
+	key, shutdown := a.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer a.queue.Done(key)
+
+	fresh, joined, err := a.analyze(ctx, key)
+	if err != nil {
+		if a.queue.NumRequeues(key) < a.maxRetries {
+			log.Printf("Agent execution failed for %s, retrying: %v", key, err)
+			a.queue.AddRateLimited(key)
+			return true
+		}
+		log.Printf("Agent execution failed for %s, giving up: %v", key, err)
+		a.queue.Forget(key)
+		return true
+	}
+	a.queue.Forget(key)
+
+	for _, incident := range fresh {
+		log.Printf("%s\n", incident)
+	}
+	parts := strings.Split(key, "|")
+	for _, incident := range joined {
+		log.Printf("Pod %s/%s joined incident %s of %s (%d affected pods)",
+			parts[0], parts[1], incident.Fingerprint, incident.Workload, len(incident.Pods))
+	}
+	return true
+}
+
+// analyze detects the failures of the container named by key and
+// recommends on the incidents they open. Failures of an open incident only
+// add the pod to it. The log cursor advances once all of that succeeded.
+func (a *PodWatchAgent) analyze(ctx context.Context, key string) (fresh, joined []*Incident, err error) {
+	result, err := a.monitor.Detect(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	fresh, joined = a.incidents.Split(result, now)
+	for _, incident := range fresh {
+		if err := a.monitor.RecommendIncident(ctx, incident); err != nil {
+			return nil, nil, err
+		}
+	}
+	a.incidents.Open(fresh, now)
+	a.monitor.commitLogCursor(ctx, result)
+	return fresh, joined, nil
+}
+
+// pruneCursors drops the log cursors of pods that are no longer in the
+// informer cache for namespace.
+func (a *PodWatchAgent) pruneCursors(namespace string) {
+	if a.cursors == nil {
+		return
+	}
+
+	livePods := make(map[string]bool)
+	objs, err := a.informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Failed to list cached pods in %s: %v", namespace, err)
+		return
+	}
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			livePods[pod.Name] = true
+		}
+	}
+
+	if err := a.cursors.Prune(namespace, livePods); err != nil {
+		log.Printf("Failed to prune log cursors in %s: %v", namespace, err)
+	}
+}
+
+// needsAnalysis reports whether a container seen for the first time is
+// already in a state worth analyzing.
+func needsAnalysis(status corev1.ContainerStatus) bool {
+	return status.RestartCount > 0 || isFailing(status)
+}
+
+// isFailing reports whether a container is currently waiting on an error or
+// terminated with one.
+func isFailing(status corev1.ContainerStatus) bool {
+	if status.State.Waiting != nil && !isBenignWaitingReason(status.State.Waiting.Reason) {
+		return true
+	}
+	return status.State.Terminated != nil && status.State.Terminated.ExitCode != 0
+}
+
+// statusTransitioned reports whether a container moved into a state that
+// warrants a new analysis since the previous observation.
+func statusTransitioned(old, cur corev1.ContainerStatus) bool {
+	if cur.RestartCount > old.RestartCount {
+		return true
+	}
+	if cur.State.Waiting != nil && !isBenignWaitingReason(cur.State.Waiting.Reason) {
+		if old.State.Waiting == nil || old.State.Waiting.Reason != cur.State.Waiting.Reason {
+			return true
+		}
+	}
+	if cur.State.Terminated != nil && old.State.Terminated == nil {
+		return true
+	}
+	return false
+}
+
+func isBenignWaitingReason(reason string) bool {
+	return reason == "" || reason == "ContainerCreating" || reason == "PodInitializing"
+}
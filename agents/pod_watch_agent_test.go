@@ -0,0 +1,78 @@
+package agents
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func waitingStatus(reason string, restarts int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         "app",
+		RestartCount: restarts,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+	}
+}
+
+func runningStatus(restarts int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         "app",
+		RestartCount: restarts,
+		State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}
+}
+
+func terminatedStatus(exitCode, restarts int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         "app",
+		RestartCount: restarts,
+		State:        corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+	}
+}
+
+func TestStatusTransitioned(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, cur corev1.ContainerStatus
+		want     bool
+	}{
+		{"restart", runningStatus(1), runningStatus(2), true},
+		{"no change", runningStatus(1), runningStatus(1), false},
+		{"starting", waitingStatus("ContainerCreating", 0), runningStatus(0), false},
+		{"into CrashLoopBackOff", runningStatus(0), waitingStatus("CrashLoopBackOff", 0), true},
+		{"still in CrashLoopBackOff", waitingStatus("CrashLoopBackOff", 3), waitingStatus("CrashLoopBackOff", 3), false},
+		{"other waiting reason", waitingStatus("ErrImagePull", 0), waitingStatus("ImagePullBackOff", 0), true},
+		{"benign waiting reason", runningStatus(0), waitingStatus("PodInitializing", 0), false},
+		{"terminated", runningStatus(0), terminatedStatus(1, 0), true},
+		{"still terminated", terminatedStatus(1, 0), terminatedStatus(1, 0), false},
+		{"completed", runningStatus(0), terminatedStatus(0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusTransitioned(tt.old, tt.cur); got != tt.want {
+				t.Errorf("statusTransitioned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFailing(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.ContainerStatus
+		want   bool
+	}{
+		{"running", runningStatus(0), false},
+		{"creating", waitingStatus("ContainerCreating", 0), false},
+		{"crashlooping", waitingStatus("CrashLoopBackOff", 4), true},
+		{"failed", terminatedStatus(137, 0), true},
+		{"completed", terminatedStatus(0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailing(tt.status); got != tt.want {
+				t.Errorf("isFailing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
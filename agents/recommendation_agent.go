@@ -1,8 +1,8 @@
 package agents
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/vasudevchavan/K8sLogmonitor/tools"
 )
@@ -18,11 +18,11 @@ func NewRecommendationAgent(tool *tools.LLMTool) *RecommendationAgent {
 	return &RecommendationAgent{llmTool: tool}
 }
 
-func (a *RecommendationAgent) GenerateRecommendation(failures []string, podName, namespace string) (string, error) {
+func (a *RecommendationAgent) GenerateRecommendation(failures []tools.Failure, podName, namespace string) (string, error) {
 	if len(failures) == 0 {
 		return "", fmt.Errorf("no failures provided")
 	}
-	context := fmt.Sprintf("Pod: %s\nNamespace: %s\nFailures:\n%s",
-		podName, namespace, strings.Join(failures, "\n"))
-	return a.llmTool.GenerateRecommendation(context)
+	podContext := fmt.Sprintf("Pod: %s\nNamespace: %s\nFailures:\n%s",
+		podName, namespace, tools.DescribeFailures(failures))
+	return a.llmTool.GenerateRecommendation(context.Background(), podContext)
 }
@@ -0,0 +1,95 @@
+package agents
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type cachedRecommendation struct {
+	text string
+	at   time.Time
+}
+
+// recommendationCache keeps recent recommendations by the fingerprints of
+// the failures they were made for, so the same failures seen again, on
+// another replica or in a later scan, do not cost another LLM call. Replicas
+// failing at the same moment wait for the recommendation in flight instead
+// of making their own.
+type recommendationCache struct {
+	ttl      time.Duration
+	inflight singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]cachedRecommendation
+}
+
+// newRecommendationCache creates a cache keeping recommendations for ttl; a
+// ttl of zero disables the cache but not the sharing of recommendations in
+// flight.
+func newRecommendationCache(ttl time.Duration) *recommendationCache {
+	return &recommendationCache{ttl: ttl, entries: make(map[string]cachedRecommendation)}
+}
+
+// do returns the recommendation for key: the cached one, the one another
+// caller is making, or else the one made by calling compute. Non-empty
+// results are cached. An empty key, failures without fingerprints, is never
+// shared.
+func (c *recommendationCache) do(key string, compute func() (string, error)) (string, error) {
+	if text, ok := c.get(key); ok {
+		log.Printf("DEBUG: Reusing the recommendation for failures %s", key)
+		return text, nil
+	}
+	if key == "" {
+		return compute()
+	}
+	text, err, shared := c.inflight.Do(key, func() (interface{}, error) {
+		// The previous call for key may have finished since the lookup
+		if text, ok := c.get(key); ok {
+			return text, nil
+		}
+		text, err := compute()
+		if err == nil && text != "" {
+			c.put(key, text)
+		}
+		return text, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if shared {
+		log.Printf("DEBUG: Shared the recommendation for failures %s", key)
+	}
+	return text.(string), nil
+}
+
+func (c *recommendationCache) get(key string) (string, bool) {
+	if c.ttl <= 0 || key == "" {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.at) > c.ttl {
+		return "", false
+	}
+	return entry.text, true
+}
+
+// put stores a recommendation and drops the expired ones.
+func (c *recommendationCache) put(key, text string) {
+	if c.ttl <= 0 || key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.Sub(entry.at) > c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedRecommendation{text: text, at: now}
+}
@@ -0,0 +1,210 @@
+package agents
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+)
+
+// ScanTarget is one container to analyze.
+type ScanTarget struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	Kind          tools.ContainerKind
+}
+
+// Input renders the target in the LogMonitorAgent input format.
+func (t ScanTarget) Input() string {
+	return strings.Join([]string{t.Namespace, t.PodName, t.ContainerName, string(t.Kind)}, "|")
+}
+
+type ScanResult struct {
+	Target   ScanTarget
+	Analysis *AnalysisResult
+	Result   string // Analysis rendered for printing
+	Err      error
+}
+
+// HasFailures reports whether the analysis found anything.
+func (r ScanResult) HasFailures() bool {
+	return r.Err == nil && r.Analysis.HasFailures()
+}
+
+// ScanEngine analyzes many containers concurrently with a bounded worker
+// pool. Per-stage limits (API server, GitHub, LLM) are enforced by the
+// adk.LimitedTool wrappers registered for the agent's tools, so a wide pool
+// does not translate into a burst of LLM calls.
+type ScanEngine struct {
+	source  tools.LogSource
+	agent   *LogMonitorAgent
+	filter  *tools.ScanFilter
+	workers int
+}
+
+// NewScanEngine creates a ScanEngine that lists containers from source, the
+// live API server or collected logs.
+func NewScanEngine(source tools.LogSource, agent *LogMonitorAgent, filter *tools.ScanFilter, workers int) *ScanEngine {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ScanEngine{source: source, agent: agent, filter: filter, workers: workers}
+}
+
+// ListTargets lists the monitored containers in namespace, or in all
+// namespaces when namespace is empty.
+func (e *ScanEngine) ListTargets(ctx context.Context, namespace string) ([]ScanTarget, error) {
+	containers, err := e.source.ListContainers(ctx, namespace, e.filter)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]ScanTarget, 0, len(containers))
+	for _, c := range containers {
+		targets = append(targets, ScanTarget{
+			Namespace:     c.Namespace,
+			PodName:       c.PodName,
+			ContainerName: c.ContainerName,
+			Kind:          c.Kind,
+		})
+	}
+	return targets, nil
+}
+
+// Scan analyzes targets and returns one result per target, in order. When
+// ctx is cancelled, in-flight analyses are aborted and targets that were not
+// started are reported with ctx.Err().
+func (e *ScanEngine) Scan(ctx context.Context, targets []ScanTarget) []ScanResult {
+	return e.scan(ctx, targets, e.agent.Analyze)
+}
+
+// ScanIncidents detects the failures of targets and groups those of a
+// workload's replicas into incidents, so each incident gets one
+// recommendation rather than one per pod. The results carry the failures
+// of each target without a recommendation. A container's log cursor only
+// advances once every incident it is part of got its recommendation, so
+// the next scan reads the logs of a failed one again.
+func (e *ScanEngine) ScanIncidents(ctx context.Context, targets []ScanTarget) ([]*Incident, []ScanResult) {
+	results := e.scan(ctx, targets, e.agent.Detect)
+	var analyses []*AnalysisResult
+	for _, res := range results {
+		if res.Err == nil {
+			analyses = append(analyses, res.Analysis)
+		}
+	}
+	incidents := GroupIncidents(analyses)
+
+	recommended := make([]bool, len(incidents))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < e.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				incident := incidents[i]
+				if err := e.agent.RecommendIncident(ctx, incident); err != nil {
+					log.Printf("Failed to recommend on incident %s/%s: %v", incident.Namespace, incident.Workload, err)
+					continue
+				}
+				recommended[i] = true
+			}
+		}()
+	}
+feed:
+	for i := range incidents {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	held := make(map[string]bool)
+	for i, incident := range incidents {
+		if recommended[i] {
+			continue
+		}
+		for _, pod := range incident.Pods {
+			held[tools.CursorKey(incident.Namespace, pod, incident.ContainerName)] = true
+		}
+	}
+	for _, analysis := range analyses {
+		if !held[tools.CursorKey(analysis.Namespace, analysis.PodName, analysis.ContainerName)] {
+			e.agent.commitLogCursor(ctx, analysis)
+		}
+	}
+	return incidents, results
+}
+
+// scan runs analyze on every target with the worker pool.
+func (e *ScanEngine) scan(ctx context.Context, targets []ScanTarget, analyze func(context.Context, string) (*AnalysisResult, error)) []ScanResult {
+	results := make([]ScanResult, len(targets))
+	for i, target := range targets {
+		results[i] = ScanResult{Target: target}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < e.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Analysis, results[i].Err = analyze(ctx, targets[i].Input())
+				if results[i].Err == nil {
+					results[i].Result = results[i].Analysis.String()
+				}
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(targets); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := next; i < len(targets); i++ {
+		results[i].Err = ctx.Err()
+	}
+	return results
+}
+
+// Namespace is the namespace scans are scoped to, or "" when the filter
+// spans several namespaces.
+func (e *ScanEngine) Namespace() string {
+	return e.filter.Namespace()
+}
+
+// ScanNamespace lists and analyzes every container in namespace, or in the
+// whole cluster when namespace is empty.
+func (e *ScanEngine) ScanNamespace(ctx context.Context, namespace string) ([]ScanResult, error) {
+	targets, err := e.ListTargets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return e.Scan(ctx, targets), nil
+}
+
+// ScanNamespaceIncidents lists every container in namespace, or in the
+// whole cluster when namespace is empty, and reports their failures as
+// incidents.
+func (e *ScanEngine) ScanNamespaceIncidents(ctx context.Context, namespace string) ([]*Incident, []ScanResult, error) {
+	targets, err := e.ListTargets(ctx, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	incidents, results := e.ScanIncidents(ctx, targets)
+	return incidents, results, nil
+}
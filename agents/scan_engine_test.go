@@ -0,0 +1,106 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/vasudevchavan/K8sLogmonitor/adk"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+)
+
+// fakeLogTool serves fixed logs per pod and records cursor commits.
+type fakeLogTool struct {
+	logs map[string]string // key: pod
+
+	mu        sync.Mutex
+	committed []string
+}
+
+func (t *fakeLogTool) Name() string {
+	return "k8s_logs"
+}
+
+func (t *fakeLogTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	pod, _ := input["pod_name"].(string)
+	if commit, _ := input["commit"].(bool); commit {
+		t.mu.Lock()
+		t.committed = append(t.committed, pod)
+		t.mu.Unlock()
+		return nil, nil
+	}
+	if previous, _ := input["previous"].(bool); previous {
+		return nil, errors.New("no previous instance")
+	}
+	return t.logs[pod], nil
+}
+
+// fakeLLMTool answers every prompt with the same recommendation.
+type fakeLLMTool struct{}
+
+func (fakeLLMTool) Name() string {
+	return "llm_recommendation"
+}
+
+func (fakeLLMTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	return "restart the database", nil
+}
+
+type staticRules struct {
+	rules *tools.RuleSet
+}
+
+func (r staticRules) Rules() *tools.RuleSet {
+	return r.rules
+}
+
+func TestScanIncidentsCommitsCursors(t *testing.T) {
+	tests := []struct {
+		name          string
+		llm           bool
+		wantCommitted []string
+	}{
+		{
+			name:          "recommended incidents",
+			llm:           true,
+			wantCommitted: []string{"api-1", "api-2", "web-1"},
+		},
+		{
+			name: "failed recommendation",
+			// Only the healthy container's cursor moves; the failing
+			// ones are read again by the next scan
+			wantCommitted: []string{"web-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logTool := &fakeLogTool{logs: map[string]string{
+				"api-1": "ERROR: connection refused to 10.0.0.2:5432",
+				"api-2": "ERROR: connection refused to 10.0.0.3:5432",
+				"web-1": "INFO serving",
+			}}
+			registry := adk.NewToolRegistry()
+			registry.RegisterTool("k8s_logs", logTool)
+			registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool(staticRules{tools.DefaultRules()}, nil))
+			if tt.llm {
+				registry.RegisterTool("llm_recommendation", fakeLLMTool{})
+			}
+			engine := &ScanEngine{agent: NewLogMonitorAgent(registry, staticRules{tools.DefaultRules()}, 0), workers: 2}
+
+			var targets []ScanTarget
+			for _, pod := range []string{"api-1", "api-2", "web-1"} {
+				targets = append(targets, ScanTarget{Namespace: "shop", PodName: pod, ContainerName: "app"})
+			}
+			incidents, _ := engine.ScanIncidents(context.Background(), targets)
+			if len(incidents) != 1 || len(incidents[0].Pods) != 2 {
+				t.Fatalf("ScanIncidents() = %v, want one incident of api-1 and api-2", incidents)
+			}
+			slices.Sort(logTool.committed)
+			if !slices.Equal(logTool.committed, tt.wantCommitted) {
+				t.Errorf("committed cursors = %v, want %v", logTool.committed, tt.wantCommitted)
+			}
+		})
+	}
+}
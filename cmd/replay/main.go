@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/adk"
+	"github.com/vasudevchavan/K8sLogmonitor/agents"
+	"github.com/vasudevchavan/K8sLogmonitor/config"
+	"github.com/vasudevchavan/K8sLogmonitor/detection"
+	"github.com/vasudevchavan/K8sLogmonitor/replay"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+)
+
+// replay analyzes a captured incident: the pod, event and node manifests in
+// dir are served by a fake clientset, and container logs are read from
+// namespace/pod/container.log files in the same directory.
+func main() {
+	dir := flag.String("dir", "", "directory with captured pod/event/node YAML and namespace/pod/container.log files")
+	flag.Parse()
+	if *dir == "" {
+		log.Fatal("-dir is required")
+	}
+
+	thresholds := config.DefaultThresholds
+
+	client, err := replay.NewClient(*dir)
+	if err != nil {
+		log.Fatalf("failed to load captured manifests: %v", err)
+	}
+	logs, err := tools.NewDirLogSource(*dir)
+	if err != nil {
+		log.Fatalf("failed to load captured logs: %v", err)
+	}
+
+	filter, err := tools.ScanFilterFromEnv()
+	if err != nil {
+		log.Fatalf("invalid monitor selection: %v", err)
+	}
+	rules, err := tools.LoadRulesFromEnv()
+	if err != nil {
+		log.Fatalf("invalid failure rules: %v", err)
+	}
+
+	registry := adk.NewToolRegistry()
+	registry.RegisterTool("k8s_logs", tools.NewK8sTool(logs, nil))
+	registry.RegisterTool("k8s_context", tools.NewK8sContextTool(client))
+	registry.RegisterTool("k8s_container_status", tools.NewContainerStatusTool(client))
+	engine := detection.NewEngine(rules)
+	registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool(engine, nil))
+	registry.RegisterTool("failure_suppression", tools.NewSuppressionTool(engine, nil))
+	registry.RegisterTool("github_issues", adk.WithLimit(tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")), adk.NewLimiter(thresholds.GitHubConcurrency)))
+	registry.RegisterTool("llm_recommendation", adk.WithLimit(tools.NewLLMTool(os.Getenv("LLM_API_KEY")), adk.NewLimiter(thresholds.LLMConcurrency)))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Containers are listed from the captured pods, so kinds, labels and
+	// annotations are honored just like on a live cluster
+	scanEngine := agents.NewScanEngine(tools.NewAPILogSource(client), agents.NewLogMonitorAgent(registry, engine, time.Duration(thresholds.RecommendationCacheMs)*time.Millisecond), filter, thresholds.ScanWorkers)
+	incidents, results, err := scanEngine.ScanNamespaceIncidents(ctx, filter.Namespace())
+	if err != nil {
+		log.Fatalf("replay failed: %v", err)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			log.Printf("Agent execution failed for %s/%s: %v", res.Target.PodName, res.Target.ContainerName, res.Err)
+		}
+	}
+	for _, incident := range incidents {
+		log.Printf("%s\n", incident)
+	}
+}
@@ -4,10 +4,74 @@ type Thresholds struct {
 	LogTailLines      int64
 	MonitorIntervalMs int
 	MaxFailuresCount  int
+	WatchResyncMs     int
+	WatchWorkers      int
+	WatchMaxRetries   int
+	StreamMaxStreams  int
+	StreamCooldownMs  int
+	ScanWorkers       int
+	ScanTimeoutMs     int
+	APIConcurrency    int
+	GitHubConcurrency int
+	LLMConcurrency    int
+	ClientQPS         float32
+	ClientBurst       int
+	EventWindowMs     int
+	EventCooldownMs   int
+	EventMaxAgeMs     int
+	// Log template anomaly detection
+	AnomalyLearnMs       int
+	AnomalyWindowMs      int
+	AnomalySimilarity    float64
+	AnomalySpikeFactor   float64
+	AnomalyMinSpikeCount int
+	// Error-rate spike detection
+	ErrorRateWindowMs      int
+	ErrorRateSensitivity   float64
+	ErrorRateMinCount      int
+	ErrorRateWarmupWindows int
+	RuleReloadMs           int
+	// How long a recommendation is reused for failures with the same
+	// fingerprints
+	RecommendationCacheMs int
+	// How long a watch-mode incident stays open for other replicas to join
+	// after its failure was last seen
+	IncidentWindowMs int
 }
 
 var DefaultThresholds = Thresholds{
 	LogTailLines:      100,
 	MonitorIntervalMs: 60000, // 1 minute
 	MaxFailuresCount:  10,
+	WatchResyncMs:     600000, // 10 minutes
+	WatchWorkers:      2,
+	WatchMaxRetries:   5,
+	StreamMaxStreams:  50,
+	StreamCooldownMs:  60000, // 1 minute
+	ScanWorkers:       16,
+	ScanTimeoutMs:     300000, // 5 minutes
+	APIConcurrency:    20,
+	GitHubConcurrency: 2,
+	LLMConcurrency:    4,
+	ClientQPS:         20,
+	ClientBurst:       40,
+	EventWindowMs:     15000,  // 15 seconds
+	EventCooldownMs:   600000, // 10 minutes
+	EventMaxAgeMs:     600000, // 10 minutes
+	// Templates seen in the first day of healthy logs form a workload's
+	// baseline
+	AnomalyLearnMs:       86400000, // 24 hours
+	AnomalyWindowMs:      300000,   // 5 minutes
+	AnomalySimilarity:    0.5,
+	AnomalySpikeFactor:   10,
+	AnomalyMinSpikeCount: 20,
+	// A container's failure matches spike when they exceed its moving
+	// average by this many standard deviations
+	ErrorRateWindowMs:      60000, // 1 minute
+	ErrorRateSensitivity:   4,
+	ErrorRateMinCount:      20,
+	ErrorRateWarmupWindows: 30,
+	RuleReloadMs:           30000,   // 30 seconds
+	RecommendationCacheMs:  1800000, // 30 minutes
+	IncidentWindowMs:       1800000, // 30 minutes
 }
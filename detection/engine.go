@@ -0,0 +1,203 @@
+package detection
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Engine is the one place log failures are detected: the detection tool and
+// the detection agent both match logs through it. It holds the
+// active RuleSet and, when the rules come from files or a ConfigMap, reloads
+// them when they change. A changed rule set is validated as a whole and
+// swapped in atomically, so every detection sees one consistent set and a
+// broken edit leaves the previous rules active.
+type Engine struct {
+	dir       string
+	client    kubernetes.Interface
+	configMap string // namespace/name
+
+	rules atomic.Pointer[tools.RuleSet]
+
+	mu          sync.Mutex // serializes reloads and guards the fields below
+	loadedAt    time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// NewEngine creates an engine with fixed rules, e.g. tools.DefaultRules.
+func NewEngine(rules *tools.RuleSet) *Engine {
+	engine := &Engine{loadedAt: time.Now()}
+	engine.rules.Store(rules)
+	return engine
+}
+
+// NewReloadingEngine creates an engine with the built-in rules plus
+// the rule files in dir and the .yaml/.yml keys of the ConfigMap configMap
+// (namespace/name, read with client), in that order; either may be empty.
+// Invalid rules fail here, later edits take effect through Reload or Watch.
+func NewReloadingEngine(ctx context.Context, dir string, client kubernetes.Interface, configMap string) (*Engine, error) {
+	if configMap != "" {
+		namespace, name, found := strings.Cut(configMap, "/")
+		if !found || namespace == "" || name == "" {
+			return nil, fmt.Errorf("rules ConfigMap must be namespace/name, got %q", configMap)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("rules ConfigMap %s needs a cluster connection", configMap)
+		}
+	}
+	engine := &Engine{dir: dir, client: client, configMap: configMap}
+	if _, err := engine.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// NewEngineFromEnv loads the rules from FAILURE_RULES_DIR and the
+// ConfigMap named by FAILURE_RULES_CONFIGMAP (namespace/name).
+func NewEngineFromEnv(ctx context.Context, client kubernetes.Interface) (*Engine, error) {
+	return NewReloadingEngine(ctx, os.Getenv("FAILURE_RULES_DIR"), client, os.Getenv("FAILURE_RULES_CONFIGMAP"))
+}
+
+// Rules returns the active rule set.
+func (e *Engine) Rules() *tools.RuleSet {
+	return e.rules.Load()
+}
+
+// Detector returns a detector over the active rules. It keeps using those
+// rules even if they are swapped while it runs.
+func (e *Engine) Detector() *tools.Detector {
+	return tools.NewDetector(e.Rules())
+}
+
+// Detect detects and aggregates the failures in logs with the active rules.
+func (e *Engine) Detect(logs string, format tools.LogFormat) []tools.Failure {
+	return e.Detector().Detect(logs, format)
+}
+
+// Reload reads the rule sources again and swaps in the new rules when they
+// changed. It reports whether they did; on error the active rules are kept.
+func (e *Engine) Reload(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules, err := e.load(ctx)
+	if err != nil {
+		e.lastError, e.lastErrorAt = err.Error(), time.Now()
+		return false, err
+	}
+	e.lastError, e.lastErrorAt = "", time.Time{}
+	if rules == nil {
+		return false, nil
+	}
+	e.rules.Store(rules)
+	e.loadedAt = time.Now()
+	return true, nil
+}
+
+// load builds the rule set from the sources, or returns nil when they are
+// unchanged. The caller must hold mu.
+func (e *Engine) load(ctx context.Context) (*tools.RuleSet, error) {
+	sources, err := tools.ReadRuleDir(e.dir)
+	if err != nil {
+		return nil, err
+	}
+	if e.configMap != "" {
+		configMapSources, err := e.readRuleConfigMap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, configMapSources...)
+	}
+	if current := e.Rules(); current != nil && current.Version() == tools.RulesVersion(sources) {
+		return nil, nil
+	}
+	return tools.BuildRuleSet(sources)
+}
+
+// readRuleConfigMap returns the rule files kept in the ConfigMap's keys, in
+// key order.
+func (e *Engine) readRuleConfigMap(ctx context.Context) ([]tools.RuleSource, error) {
+	namespace, name, _ := strings.Cut(e.configMap, "/")
+	configMap, err := e.client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules ConfigMap %s: %w", e.configMap, err)
+	}
+	var keys []string
+	for key := range configMap.Data {
+		if tools.IsRuleFileName(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	sources := make([]tools.RuleSource, len(keys))
+	for i, key := range keys {
+		sources[i] = tools.RuleSource{Name: e.configMap + ":" + key, Data: []byte(configMap.Data[key])}
+	}
+	return sources, nil
+}
+
+// Watch reloads the rules every interval until ctx is done. Engines with
+// fixed rules have nothing to watch.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.dir == "" && e.configMap == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := e.Reload(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Keeping failure rules %s, reload failed: %v", e.Rules().Version(), err)
+		case changed:
+			rules := e.Rules()
+			log.Printf("Failure rules reloaded: version %s, %d rules, %d suppressions",
+				rules.Version(), len(rules.All()), len(rules.Suppressions()))
+		}
+	}
+}
+
+// RulesStatus describes the active rules and the outcome of the last reload.
+type RulesStatus struct {
+	Version      string               `json:"version"`
+	LoadedAt     time.Time            `json:"loaded_at"`
+	Sources      []string             `json:"sources"`
+	Rules        []*tools.Rule        `json:"rules"`
+	Suppressions []*tools.Suppression `json:"suppressions"`
+	// LastError is set while the rule sources fail to load
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+}
+
+// Status describes the active rules and the last reload.
+func (e *Engine) Status() RulesStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rules := e.Rules()
+	return RulesStatus{
+		Version:      rules.Version(),
+		LoadedAt:     e.loadedAt,
+		Sources:      rules.Sources(),
+		Rules:        rules.All(),
+		Suppressions: rules.Suppressions(),
+		LastError:    e.lastError,
+		LastErrorAt:  e.lastErrorAt,
+	}
+}
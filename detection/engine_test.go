@@ -0,0 +1,99 @@
+package detection
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const retryBudgetRule = `
+rules:
+  - id: retry-budget
+    pattern: 'retry budget'
+    category: capacity
+    severity: high
+`
+
+func ruleIDs(e *Engine) []string {
+	var ids []string
+	for _, rule := range e.Rules().All() {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+func TestEngineReload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.yaml")
+	if err := os.WriteFile(path, []byte(retryBudgetRule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewReloadingEngine(ctx, dir, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(ruleIDs(engine), "retry-budget") {
+		t.Fatalf("rules = %v, want retry-budget", ruleIDs(engine))
+	}
+	version := engine.Rules().Version()
+
+	if changed, err := engine.Reload(ctx); changed || err != nil {
+		t.Errorf("Reload() of unchanged rules = %v, %v, want false, nil", changed, err)
+	}
+
+	// A broken edit keeps the previous rules
+	if err := os.WriteFile(path, []byte("rules:\n  - id: broken\n    pattern: '('\n    category: capacity\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := engine.Reload(ctx); changed || err == nil {
+		t.Fatalf("Reload() of a broken rule = %v, %v, want an error", changed, err)
+	}
+	if engine.Rules().Version() != version || !slices.Contains(ruleIDs(engine), "retry-budget") {
+		t.Errorf("rules after a failed reload = %s %v, want version %s", engine.Rules().Version(), ruleIDs(engine), version)
+	}
+	if status := engine.Status(); status.LastError == "" {
+		t.Error("Status() has no error after a failed reload")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := engine.Reload(ctx); !changed || err != nil {
+		t.Fatalf("Reload() after the fix = %v, %v, want true, nil", changed, err)
+	}
+	if slices.Contains(ruleIDs(engine), "retry-budget") {
+		t.Error("removed rule retry-budget is still active")
+	}
+	if status := engine.Status(); status.LastError != "" {
+		t.Errorf("Status() error = %q after a successful reload", status.LastError)
+	}
+}
+
+func TestEngineReloadConfigMap(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "failure-rules"},
+		Data:       map[string]string{"custom.yaml": retryBudgetRule, "README": "not a rule file"},
+	})
+	engine, err := NewReloadingEngine(ctx, "", client, "monitoring/failure-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(ruleIDs(engine), "retry-budget") {
+		t.Errorf("rules = %v, want retry-budget", ruleIDs(engine))
+	}
+	if sources := engine.Rules().Sources(); !slices.Equal(sources, []string{"monitoring/failure-rules:custom.yaml"}) {
+		t.Errorf("Sources() = %v", sources)
+	}
+
+	if _, err := NewReloadingEngine(ctx, "", client, "failure-rules"); err == nil {
+		t.Error("NewReloadingEngine() accepted a ConfigMap without a namespace")
+	}
+}
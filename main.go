@@ -2,61 +2,199 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/vasudevchavan/K8sLogmonitor/adk"
 	"github.com/vasudevchavan/K8sLogmonitor/agents"
+	"github.com/vasudevchavan/K8sLogmonitor/config"
+	"github.com/vasudevchavan/K8sLogmonitor/detection"
 	"github.com/vasudevchavan/K8sLogmonitor/tools"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 )
 
 func main() {
+	follow := flag.Bool("follow", false, "stream container logs and analyze failures as they are logged")
+	events := flag.Bool("events", true, "analyze Kubernetes Warning events as failures of their own in watch mode")
+	once := flag.Bool("once", false, "scan every container once with the concurrent scan engine and exit")
+	logsPath := flag.String("logs", "", "analyze collected logs (a namespace/pod/container.log directory or a .tar.gz support bundle) once instead of a live cluster")
+	flag.Parse()
+
+	thresholds := config.DefaultThresholds
+
+	// Namespaces, selectors, container exclusions and opt-out annotations
+	// decide what every scan path looks at
+	filter, err := tools.ScanFilterFromEnv()
+	if err != nil {
+		log.Fatalf("invalid monitor selection: %v", err)
+	}
+
+	// Silences are created through the web UI's API and shared through
+	// SILENCE_FILE
+	silences, err := tools.NewSilenceStoreFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load silences: %v", err)
+	}
+	// Per-container error-rate baselines; ERROR_RATE_SENSITIVITY tunes when
+	// a rate counts as a spike
+	rates, err := tools.NewErrorRateTrackerFromEnv()
+	if err != nil {
+		log.Fatalf("invalid error rate settings: %v", err)
+	}
+	// A single scan has no history to measure a rate against
+	if *once {
+		rates = nil
+	}
+	// The same failures, by fingerprint, reuse their recommendation for a
+	// while instead of another GitHub search and LLM call
+	recommendationTTL := time.Duration(thresholds.RecommendationCacheMs) * time.Millisecond
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *logsPath != "" {
+		source, err := tools.NewOfflineLogSource(*logsPath)
+		if err != nil {
+			log.Fatalf("failed to open collected logs: %v", err)
+		}
+		// A one-off analysis reads the team rules in FAILURE_RULES_DIR once
+		rules, err := tools.LoadRulesFromEnv()
+		if err != nil {
+			log.Fatalf("invalid failure rules: %v", err)
+		}
+		engine := detection.NewEngine(rules)
+		// Collected logs have no cluster behind them, so the status and
+		// context tools are not registered
+		registry := adk.NewToolRegistry()
+		registry.RegisterTool("k8s_logs", tools.NewK8sTool(source, nil))
+		registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool(engine, nil))
+		registry.RegisterTool("failure_suppression", tools.NewSuppressionTool(engine, silences))
+		// Templates of collected logs are learned for this run only, apart
+		// from the live baselines in ANOMALY_STATE_DIR
+		registry.RegisterTool("anomaly_detection", tools.NewAnomalyDetectionTool(tools.NewMemoryTemplateStore()))
+		registry.RegisterTool("github_issues", adk.WithLimit(tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")), adk.NewLimiter(thresholds.GitHubConcurrency)))
+		registry.RegisterTool("llm_recommendation", adk.WithLimit(tools.NewLLMTool(os.Getenv("LLM_API_KEY")), adk.NewLimiter(thresholds.LLMConcurrency)))
+
+		scanEngine := agents.NewScanEngine(source, agents.NewLogMonitorAgent(registry, engine, recommendationTTL), filter, thresholds.ScanWorkers)
+		runScan(ctx, scanEngine, filter.Namespace())
+		return
+	}
+
+	// The live monitor looks at the default namespace unless
+	// MONITOR_INCLUDE_NAMESPACES selects others; it is scoped to a single
+	// included namespace and otherwise covers the cluster
+	namespace := "default"
+	if len(filter.IncludeNamespaces) > 0 {
+		namespace = filter.Namespace()
+	}
+
 	// Initialize Kubernetes client
 	k8sClient, err := tools.NewK8sClient()
 	if err != nil {
 		log.Fatalf("failed to initialize k8s client: %v", err)
 	}
 
+	// Persist per-container log cursors so a restarted monitor does not
+	// re-analyze and re-report logs it has already seen
+	cursors, err := tools.NewCursorStoreFromEnv(k8sClient)
+	if err != nil {
+		log.Fatalf("failed to initialize log cursor store: %v", err)
+	}
+
+	// Built-in failure rules plus the team rules in FAILURE_RULES_DIR and
+	// FAILURE_RULES_CONFIGMAP; edits take effect without a restart
+	engine, err := detection.NewEngineFromEnv(ctx, k8sClient)
+	if err != nil {
+		log.Fatalf("invalid failure rules: %v", err)
+	}
+	go engine.Watch(ctx, time.Duration(thresholds.RuleReloadMs)*time.Millisecond)
+
+	// Log templates learned per workload, kept in ANOMALY_STATE_DIR
+	templates := tools.NewTemplateStoreFromEnv()
+
 	// Initialize ADK registry and tools
 	registry := adk.NewToolRegistry()
-	
-	// Register tools
-	registry.RegisterTool("k8s_logs", tools.NewK8sTool(k8sClient))
-	registry.RegisterTool("k8s_context", tools.NewK8sContextTool(k8sClient))
-	registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool())
-	registry.RegisterTool("github_issues", tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")))
-	registry.RegisterTool("llm_recommendation", tools.NewLLMTool(os.Getenv("LLM_API_KEY")))
 
-	// Initialize log monitor agent
-	logMonitorAgent := agents.NewLogMonitorAgent(registry)
+	// Register tools; tools that hit the same backend share a concurrency limit
+	apiLimit := adk.NewLimiter(thresholds.APIConcurrency)
+	source := tools.NewAPILogSource(k8sClient)
+	registry.RegisterTool("k8s_logs", adk.WithLimit(tools.NewK8sTool(source, cursors), apiLimit))
+	registry.RegisterTool("k8s_context", adk.WithLimit(tools.NewK8sContextTool(k8sClient), apiLimit))
+	registry.RegisterTool("k8s_container_status", adk.WithLimit(tools.NewContainerStatusTool(k8sClient), apiLimit))
+	registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool(engine, rates))
+	registry.RegisterTool("failure_suppression", tools.NewSuppressionTool(engine, silences))
+	registry.RegisterTool("anomaly_detection", tools.NewAnomalyDetectionTool(templates))
+	registry.RegisterTool("github_issues", adk.WithLimit(tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")), adk.NewLimiter(thresholds.GitHubConcurrency)))
+	registry.RegisterTool("llm_recommendation", adk.WithLimit(tools.NewLLMTool(os.Getenv("LLM_API_KEY")), adk.NewLimiter(thresholds.LLMConcurrency)))
 
-	const namespace = "default"
-	const monitorInterval = 1 * time.Minute
+	// Initialize log monitor agent
+	logMonitorAgent := agents.NewLogMonitorAgent(registry, engine, recommendationTTL)
 
-	ticker := time.NewTicker(monitorInterval)
-	for range ticker.C {
-		// Get all pods in namespace
-		pods, err := k8sClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
-		if err != nil {
-			log.Printf("Error listing pods: %v", err)
-			continue
+	if *once {
+		runScan(ctx, agents.NewScanEngine(source, logMonitorAgent, filter, thresholds.ScanWorkers), namespace)
+		if err := cursors.Flush(); err != nil {
+			log.Printf("failed to save log cursors: %v", err)
 		}
+		return
+	}
 
-		for _, pod := range pods.Items {
-			for _, container := range pod.Spec.Containers {
-				input := strings.Join([]string{namespace, pod.Name, container.Name}, "|")
-				result, err := logMonitorAgent.Execute(context.Background(), input)
-				if err != nil {
-					log.Printf("Agent execution failed for %s/%s: %v", pod.Name, container.Name, err)
-					continue
-				}
-				if result != "No failures detected" {
-					log.Printf("Pod: %s/%s\n%s\n", pod.Name, container.Name, result)
-				}
+	// Watch pods through a shared informer and only analyze containers whose
+	// status changes, instead of re-listing the namespace on a ticker
+	resync := time.Duration(thresholds.WatchResyncMs) * time.Millisecond
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sClient, resync,
+		informers.WithNamespace(namespace), informers.WithTweakListOptions(filter.TweakListOptions))
+	watchAgent := agents.NewPodWatchAgent(factory, logMonitorAgent, filter, cursors, thresholds.WatchMaxRetries,
+		time.Duration(thresholds.IncidentWindowMs)*time.Millisecond)
+
+	if *follow {
+		// Failures seen in a followed stream go through the watch queue so a
+		// burst of error lines results in a single analysis
+		cooldown := time.Duration(thresholds.StreamCooldownMs) * time.Millisecond
+		streamAgent := agents.NewLogStreamAgent(k8sClient, factory, registry, filter, thresholds.StreamMaxStreams, cooldown,
+			func(namespace, podName, containerName string, kind tools.ContainerKind, failures []tools.Failure) {
+				watchAgent.Enqueue(namespace, podName, containerName, kind)
+			})
+		go streamAgent.Run(ctx)
+	}
+
+	if *events {
+		// Warning events are watched without the pod selectors, which do not
+		// apply to events; the ScanFilter is applied per event instead
+		eventFactory := informers.NewSharedInformerFactoryWithOptions(k8sClient, resync, informers.WithNamespace(namespace))
+		eventAgent := agents.NewEventWatchAgent(eventFactory, factory.Core().V1().Pods().Informer(), logMonitorAgent, filter,
+			time.Duration(thresholds.EventWindowMs)*time.Millisecond,
+			time.Duration(thresholds.EventCooldownMs)*time.Millisecond,
+			time.Duration(thresholds.EventMaxAgeMs)*time.Millisecond,
+			thresholds.WatchMaxRetries)
+		go func() {
+			if err := eventAgent.Run(ctx, thresholds.WatchWorkers); err != nil {
+				log.Printf("event watch failed: %v", err)
 			}
+		}()
+	}
+
+	if err := watchAgent.Run(ctx, thresholds.WatchWorkers); err != nil {
+		log.Fatalf("pod watch failed: %v", err)
+	}
+}
+
+// runScan analyzes every container once and prints the failures found, one
+// incident per workload container with the same failures.
+func runScan(ctx context.Context, engine *agents.ScanEngine, namespace string) {
+	incidents, results, err := engine.ScanNamespaceIncidents(ctx, namespace)
+	if err != nil {
+		log.Fatalf("scan failed: %v", err)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			log.Printf("Agent execution failed for %s/%s: %v", res.Target.PodName, res.Target.ContainerName, res.Err)
 		}
 	}
-}
\ No newline at end of file
+	for _, incident := range incidents {
+		log.Printf("%s\n", incident)
+	}
+}
@@ -0,0 +1,111 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// LoadObjects reads every Kubernetes object from the YAML and JSON
+// files under dir, such as the output of kubectl get pods,events,nodes -o
+// yaml. Multi-document files and List objects are expanded; kinds the client
+// does not know are skipped.
+func LoadObjects(dir string) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(filePath)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		fileObjects, err := decodeManifestFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", filePath, err)
+		}
+		objects = append(objects, fileObjects...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// NewClient returns a fake clientset serving the objects captured
+// under dir, so agents and tools can run against a past incident exactly as
+// against a live cluster.
+func NewClient(dir string) (kubernetes.Interface, error) {
+	objects, err := LoadObjects(dir)
+	if err != nil {
+		return nil, err
+	}
+	return fake.NewClientset(objects...), nil
+}
+
+func decodeManifestFile(filePath string) ([]runtime.Object, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var objects []runtime.Object
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		docObjects, err := decodeManifest(doc)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, docObjects...)
+	}
+}
+
+func decodeManifest(data []byte) ([]runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.(*corev1.List)
+	if !ok {
+		return []runtime.Object{obj}, nil
+	}
+	var objects []runtime.Object
+	for _, item := range list.Items {
+		itemObjects, err := decodeManifest(item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, itemObjects...)
+	}
+	return objects, nil
+}
@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AnomalyDetectionTool flags log lines that no rule knows about: templates
+// a workload never logged while healthy, and templates whose frequency
+// spikes.
+type AnomalyDetectionTool struct {
+	store *TemplateStore
+}
+
+func NewAnomalyDetectionTool(store *TemplateStore) *AnomalyDetectionTool {
+	return &AnomalyDetectionTool{store: store}
+}
+
+func (t *AnomalyDetectionTool) Name() string {
+	return "anomaly_detection"
+}
+
+// Execute mines input["logs"] for input["workload"] (Workload) in
+// input["namespace"] and returns the anomalies as []Failure of category
+// "anomaly". input["healthy"] tells whether the analysis found no other
+// failures, which decides whether the logs may join the baseline.
+func (t *AnomalyDetectionTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	logs, ok := input["logs"].(string)
+	if !ok {
+		return nil, errors.New("logs must be a string")
+	}
+	workload, ok := input["workload"].(Workload)
+	if !ok || workload.Name == "" {
+		return nil, errors.New("workload must be a Workload")
+	}
+	namespace, _ := input["namespace"].(string)
+	healthy, _ := input["healthy"].(bool)
+
+	return t.store.Observe(namespace, workload, logs, healthy, time.Now())
+}
@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CursorStore persists the timestamp of the last processed log line per
+// container so a restarted monitor resumes where it stopped instead of
+// re-analyzing the whole tail. Keys are namespace/pod/container.
+type CursorStore interface {
+	Get(key string) (time.Time, error)
+	Set(key string, t time.Time) error
+	// Prune drops the cursors of pods in namespace that are not in livePods.
+	Prune(namespace string, livePods map[string]bool) error
+	// Flush writes pending changes to the backing storage.
+	Flush() error
+}
+
+// CursorKey builds the key cursors are stored under.
+func CursorKey(namespace, podName, containerName string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, podName, containerName)
+}
+
+// NewCursorStoreFromEnv picks the cursor store from the environment:
+// LOG_CURSOR_CONFIGMAP=namespace/name stores cursors in a ConfigMap,
+// LOG_CURSOR_FILE=path in a local JSON file, otherwise they are kept in memory.
+func NewCursorStoreFromEnv(client kubernetes.Interface) (CursorStore, error) {
+	if ref := os.Getenv("LOG_CURSOR_CONFIGMAP"); ref != "" {
+		namespace, name, found := strings.Cut(ref, "/")
+		if !found || namespace == "" || name == "" {
+			return nil, fmt.Errorf("LOG_CURSOR_CONFIGMAP must be namespace/name, got %q", ref)
+		}
+		return NewConfigMapCursorStore(context.Background(), client, namespace, name)
+	}
+	if path := os.Getenv("LOG_CURSOR_FILE"); path != "" {
+		return NewFileCursorStore(path)
+	}
+	return NewMemoryCursorStore(), nil
+}
+
+// MemoryCursorStore keeps cursors for the lifetime of the process.
+type MemoryCursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]time.Time
+}
+
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return &MemoryCursorStore{cursors: make(map[string]time.Time)}
+}
+
+func (s *MemoryCursorStore) Get(key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors[key], nil
+}
+
+func (s *MemoryCursorStore) Set(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[key] = t
+	return nil
+}
+
+func (s *MemoryCursorStore) Prune(namespace string, livePods map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruneCursors(s.cursors, namespace, livePods)
+	return nil
+}
+
+func (s *MemoryCursorStore) Flush() error {
+	return nil
+}
+
+// FileCursorStore keeps cursors in a JSON file that is rewritten atomically
+// on Flush.
+type FileCursorStore struct {
+	MemoryCursorStore
+	path string
+}
+
+func NewFileCursorStore(path string) (*FileCursorStore, error) {
+	store := &FileCursorStore{
+		MemoryCursorStore: MemoryCursorStore{cursors: make(map[string]time.Time)},
+		path:              path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cursor file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &store.cursors); err != nil {
+		return nil, fmt.Errorf("failed to parse cursor file %s: %w", path, err)
+	}
+	return store, nil
+}
+
+func (s *FileCursorStore) Flush() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.cursors, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode cursors: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write cursor file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path through a temporary file in the
+// same directory, so readers never see a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ConfigMapCursorStore keeps cursors in a ConfigMap so they survive the
+// monitor's pod being rescheduled. The ConfigMap is created on first Flush.
+type ConfigMapCursorStore struct {
+	MemoryCursorStore
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	dirty     bool
+}
+
+func NewConfigMapCursorStore(ctx context.Context, client kubernetes.Interface, namespace, name string) (*ConfigMapCursorStore, error) {
+	store := &ConfigMapCursorStore{
+		MemoryCursorStore: MemoryCursorStore{cursors: make(map[string]time.Time)},
+		client:            client,
+		namespace:         namespace,
+		name:              name,
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cursor configmap %s/%s: %w", namespace, name, err)
+	}
+	for dataKey, value := range cm.Data {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			continue
+		}
+		store.cursors[strings.ReplaceAll(dataKey, "_", "/")] = t
+	}
+	return store, nil
+}
+
+func (s *ConfigMapCursorStore) Set(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[key] = t
+	s.dirty = true
+	return nil
+}
+
+func (s *ConfigMapCursorStore) Prune(namespace string, livePods map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pruneCursors(s.cursors, namespace, livePods) > 0 {
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *ConfigMapCursorStore) Flush() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	// ConfigMap keys may not contain '/', and '_' never appears in pod,
+	// namespace or container names
+	data := make(map[string]string, len(s.cursors))
+	for key, t := range s.cursors {
+		data[strings.ReplaceAll(key, "/", "_")] = t.Format(time.RFC3339Nano)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+			Data:       data,
+		}, metav1.CreateOptions{})
+	} else if err == nil {
+		cm.Data = data
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save cursor configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return nil
+}
+
+func pruneCursors(cursors map[string]time.Time, namespace string, livePods map[string]bool) int {
+	pruned := 0
+	for key := range cursors {
+		parts := strings.SplitN(key, "/", 3)
+		if len(parts) != 3 || parts[0] != namespace {
+			continue
+		}
+		if !livePods[parts[1]] {
+			delete(cursors, key)
+			pruned++
+		}
+	}
+	return pruned
+}
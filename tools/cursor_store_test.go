@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCursorStoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		// open returns a function opening the store on the same storage
+		open func(t *testing.T) func() (CursorStore, error)
+	}{
+		{
+			name: "file",
+			open: func(t *testing.T) func() (CursorStore, error) {
+				path := filepath.Join(t.TempDir(), "cursors.json")
+				return func() (CursorStore, error) { return NewFileCursorStore(path) }
+			},
+		},
+		{
+			name: "configmap",
+			open: func(t *testing.T) func() (CursorStore, error) {
+				client := fake.NewSimpleClientset()
+				return func() (CursorStore, error) {
+					return NewConfigMapCursorStore(context.Background(), client, "monitoring", "log-cursors")
+				}
+			},
+		},
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := tt.open(t)
+			store, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := store.Get(CursorKey("shop", "api-0", "app")); !got.IsZero() {
+				t.Fatalf("Get() on a new store = %v, want zero", got)
+			}
+			for _, key := range []string{CursorKey("shop", "api-0", "app"), CursorKey("shop", "api-1", "app"), CursorKey("billing", "db-0", "postgres")} {
+				if err := store.Set(key, at); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.Prune("shop", map[string]bool{"api-0": true}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cursors := []struct {
+				key  string
+				want time.Time
+			}{
+				{CursorKey("shop", "api-0", "app"), at},
+				{CursorKey("shop", "api-1", "app"), time.Time{}},
+				{CursorKey("billing", "db-0", "postgres"), at},
+			}
+			for _, cursor := range cursors {
+				got, err := reopened.Get(cursor.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !got.Equal(cursor.want) {
+					t.Errorf("Get(%s) after reopening = %v, want %v", cursor.key, got, cursor.want)
+				}
+			}
+
+			// Flushing again updates the stored cursors in place
+			later := at.Add(time.Minute)
+			if err := reopened.Set(CursorKey("shop", "api-0", "app"), later); err != nil {
+				t.Fatal(err)
+			}
+			if err := reopened.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			again, err := open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := again.Get(CursorKey("shop", "api-0", "app")); !got.Equal(later) {
+				t.Errorf("Get() after the second flush = %v, want %v", got, later)
+			}
+		})
+	}
+}
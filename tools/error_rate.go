@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	appconfig "github.com/vasudevchavan/K8sLogmonitor/config"
+)
+
+const (
+	// errorRateWeight is the weight of the latest window in a container's
+	// moving average of matches per window.
+	errorRateWeight = 0.1
+	// errorRateIdle is how long a container goes unanalyzed before its
+	// state is dropped, e.g. because its pod was deleted.
+	errorRateIdle = 24 * time.Hour
+)
+
+var errorRateSpikeRule = &Rule{
+	ID:          "error-rate-spike",
+	Category:    "error-rate",
+	Severity:    SeverityHigh,
+	Description: "The container logs failures far more often than it usually does.",
+	Remediation: "Check what changed when the rate went up, e.g. a deploy, a failing dependency or a traffic change, and the failures that make up the spike.",
+}
+
+// ErrorRate is a container's current rate of failure matches and its
+// baseline, in matches per window.
+type ErrorRate struct {
+	Window   time.Duration
+	Rate     int
+	Baseline float64
+	StdDev   float64
+	// Warm is set once the baseline has seen enough windows to be trusted
+	Warm bool
+}
+
+// errorRateSecond counts the matches that occurred in one second.
+type errorRateSecond struct {
+	at    time.Time
+	count int
+}
+
+type errorRateState struct {
+	// seconds holds the matches of the sliding window, oldest first
+	seconds []errorRateSecond
+	// latest is the newest match timestamp counted, so lines read again by
+	// a later analysis are not counted twice
+	latest time.Time
+	// sampled is where the next window folded into the baseline starts
+	sampled  time.Time
+	observed time.Time
+	mean     float64
+	variance float64
+	windows  int
+}
+
+// ErrorRateTracker keeps the rate of failure matches per container over a
+// sliding window and an exponentially weighted baseline of it, so a
+// container that always logs a few errors is told apart from one whose
+// errors just went up a hundredfold.
+type ErrorRateTracker struct {
+	window      time.Duration
+	sensitivity float64
+	minCount    int
+	warmup      int
+
+	mu         sync.Mutex
+	containers map[string]*errorRateState // key: namespace/pod/container
+	swept      time.Time
+}
+
+// NewErrorRateTracker creates a tracker measuring rates over window. A rate
+// spikes when it is at least minCount matches and more than sensitivity
+// standard deviations above the baseline, once the baseline has seen warmup
+// windows.
+func NewErrorRateTracker(window time.Duration, sensitivity float64, minCount, warmup int) *ErrorRateTracker {
+	return &ErrorRateTracker{
+		window:      window,
+		sensitivity: sensitivity,
+		minCount:    minCount,
+		warmup:      warmup,
+		containers:  make(map[string]*errorRateState),
+	}
+}
+
+// NewErrorRateTrackerFromEnv creates a tracker with the default thresholds,
+// with the sensitivity overridden by ERROR_RATE_SENSITIVITY when it is set.
+func NewErrorRateTrackerFromEnv() (*ErrorRateTracker, error) {
+	thresholds := appconfig.DefaultThresholds
+	sensitivity := thresholds.ErrorRateSensitivity
+	if value := os.Getenv("ERROR_RATE_SENSITIVITY"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("ERROR_RATE_SENSITIVITY must be a positive number, got %q", value)
+		}
+		sensitivity = parsed
+	}
+	return NewErrorRateTracker(time.Duration(thresholds.ErrorRateWindowMs)*time.Millisecond,
+		sensitivity, thresholds.ErrorRateMinCount, thresholds.ErrorRateWarmupWindows), nil
+}
+
+// Observe counts the occurrences detected for a container, one failure per
+// matching log event, and returns the container's rate. Occurrences without
+// a log timestamp are counted at now. The baseline advances by time rather
+// than by call: every window that ended since the last observation enters it
+// once, with zero matches when the container logged no failures, however
+// often or rarely the container is analyzed.
+func (t *ErrorRateTracker) Observe(containerKey string, occurrences []Failure, now time.Time) ErrorRate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(now)
+	state, ok := t.containers[containerKey]
+	if !ok {
+		state = &errorRateState{sampled: now}
+		t.containers[containerKey] = state
+	}
+	state.observed = now
+
+	latest := state.latest
+	for _, occurrence := range occurrences {
+		at := occurrence.FirstSeen
+		if at.IsZero() {
+			at = now
+		} else if !at.After(state.latest) {
+			continue
+		}
+		state.add(at.Truncate(time.Second))
+		if at.After(latest) {
+			latest = at
+		}
+	}
+	state.latest = latest
+
+	// Windows that ended before the current one are folded in first; the
+	// current rate is compared with the baseline before the windows it
+	// overlaps are
+	cutoff := now.Add(-t.window)
+	t.advance(state, cutoff)
+	count := 0
+	for _, second := range state.seconds {
+		if second.at.After(cutoff) {
+			count += second.count
+		}
+	}
+	rate := ErrorRate{
+		Window:   t.window,
+		Rate:     count,
+		Baseline: state.mean,
+		StdDev:   math.Sqrt(state.variance),
+		Warm:     state.windows >= t.warmup,
+	}
+	t.advance(state, now)
+
+	for len(state.seconds) > 0 && !state.seconds[0].at.After(cutoff) {
+		state.seconds = state.seconds[1:]
+	}
+	return rate
+}
+
+// advance folds every window that ended by until into the baseline.
+func (t *ErrorRateTracker) advance(state *errorRateState, until time.Time) {
+	for end := state.sampled.Add(t.window); !end.After(until); end = end.Add(t.window) {
+		state.sample(float64(state.count(state.sampled, end)))
+		state.sampled = end
+	}
+}
+
+// count returns the matches from start up to, but not including, end.
+func (s *errorRateState) count(start, end time.Time) int {
+	n := 0
+	for _, second := range s.seconds {
+		if !second.at.Before(start) && second.at.Before(end) {
+			n += second.count
+		}
+	}
+	return n
+}
+
+// add counts one match at second, keeping seconds ordered; occurrences
+// arrive mostly in order, so the search starts from the end.
+func (s *errorRateState) add(second time.Time) {
+	i := len(s.seconds)
+	for i > 0 && s.seconds[i-1].at.After(second) {
+		i--
+	}
+	if i > 0 && s.seconds[i-1].at.Equal(second) {
+		s.seconds[i-1].count++
+		return
+	}
+	s.seconds = append(s.seconds, errorRateSecond{})
+	copy(s.seconds[i+1:], s.seconds[i:])
+	s.seconds[i] = errorRateSecond{at: second, count: 1}
+}
+
+// sample folds a window's rate into the exponentially weighted mean and
+// variance.
+func (s *errorRateState) sample(rate float64) {
+	if s.windows == 0 {
+		s.mean = rate
+	} else {
+		diff := rate - s.mean
+		increment := errorRateWeight * diff
+		s.mean += increment
+		s.variance = (1 - errorRateWeight) * (s.variance + diff*increment)
+	}
+	s.windows++
+}
+
+// Spike returns a failure when rate deviates significantly from its
+// baseline.
+func (t *ErrorRateTracker) Spike(rate ErrorRate) (Failure, bool) {
+	if !rate.Warm || rate.Rate < t.minCount {
+		return Failure{}, false
+	}
+	// A steady baseline has almost no deviation, which would make any
+	// extra match significant
+	deviation := math.Max(rate.StdDev, 1)
+	if float64(rate.Rate) <= rate.Baseline+t.sensitivity*deviation {
+		return Failure{}, false
+	}
+	message := fmt.Sprintf("error rate spiked to %d matches per %s (baseline %.1f ± %.1f)",
+		rate.Rate, rate.Window, rate.Baseline, rate.StdDev)
+	return newFailure(message, "", 0, time.Time{}, errorRateSpikeRule), true
+}
+
+// sweep drops the state of idle containers, at most once per window. The
+// caller must hold mu.
+func (t *ErrorRateTracker) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.window {
+		return
+	}
+	t.swept = now
+	for key, state := range t.containers {
+		if now.Sub(state.observed) > errorRateIdle {
+			delete(t.containers, key)
+		}
+	}
+}
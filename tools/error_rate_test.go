@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// occurrences returns one failure per offset from start, or without a
+// timestamp for a negative offset.
+func occurrences(start time.Time, offsets ...time.Duration) []Failure {
+	failures := make([]Failure, len(offsets))
+	for i, offset := range offsets {
+		if offset >= 0 {
+			failures[i].FirstSeen = start.Add(offset)
+		}
+	}
+	return failures
+}
+
+func TestErrorRateTrackerWindow(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at    time.Duration
+		found []time.Duration
+		want  int
+	}{
+		{time.Minute, []time.Duration{10 * time.Second, 40 * time.Second}, 2},
+		// Lines read again are not counted twice
+		{time.Minute + 20*time.Second, []time.Duration{10 * time.Second, 40 * time.Second, 70 * time.Second}, 2},
+		// Matches in the same second add up
+		{time.Minute + 30*time.Second, []time.Duration{75 * time.Second, 75 * time.Second}, 4},
+		// Failures without a timestamp count at now
+		{2 * time.Minute, []time.Duration{-1}, 4},
+		{3*time.Minute + 20*time.Second, nil, 0},
+	}
+	tracker := NewErrorRateTracker(time.Minute, 4, 20, 1)
+	for i, step := range steps {
+		rate := tracker.Observe("shop/api-0/app", occurrences(start, step.found...), start.Add(step.at))
+		if rate.Rate != step.want || rate.Window != time.Minute {
+			t.Errorf("step %d: Observe() rate = %d per %s, want %d per 1m0s", i, rate.Rate, rate.Window, step.want)
+		}
+	}
+}
+
+func TestErrorRateTrackerBaseline(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at           time.Duration
+		found        []time.Duration
+		wantBaseline float64
+		wantWarm     bool
+	}{
+		{0, nil, 0, false},
+		{time.Minute, []time.Duration{10 * time.Second, 20 * time.Second}, 0, false},
+		{2 * time.Minute, []time.Duration{70 * time.Second, 80 * time.Second}, 2, false},
+		// Compared before the windows it overlaps are folded in
+		{3 * time.Minute, []time.Duration{130 * time.Second, 140 * time.Second}, 2, true},
+		// Windows without an analysis enter the baseline with zero matches
+		{6 * time.Minute, nil, 2 * 0.9 * 0.9, true},
+		// Calls within a window do not fold it in again
+		{6*time.Minute + 10*time.Second, nil, 2 * 0.9 * 0.9 * 0.9, true},
+		{6*time.Minute + 20*time.Second, nil, 2 * 0.9 * 0.9 * 0.9, true},
+	}
+	tracker := NewErrorRateTracker(time.Minute, 4, 20, 2)
+	for i, step := range steps {
+		rate := tracker.Observe("shop/api-0/app", occurrences(start, step.found...), start.Add(step.at))
+		if math.Abs(rate.Baseline-step.wantBaseline) > 1e-9 || rate.Warm != step.wantWarm {
+			t.Errorf("step %d: Observe() baseline = %.3f, warm %v, want %.3f, %v", i, rate.Baseline, rate.Warm, step.wantBaseline, step.wantWarm)
+		}
+	}
+}
+
+func TestErrorRateTrackerSpike(t *testing.T) {
+	tests := []struct {
+		name string
+		rate ErrorRate
+		want bool
+	}{
+		{"cold baseline", ErrorRate{Rate: 100, Baseline: 1}, false},
+		{"below the minimum count", ErrorRate{Rate: 19, Baseline: 0, Warm: true}, false},
+		{"at the minimum count", ErrorRate{Rate: 20, Baseline: 0, Warm: true}, true},
+		{"steady baseline", ErrorRate{Rate: 25, Baseline: 20, StdDev: 0, Warm: true}, true},
+		{"within the deviation", ErrorRate{Rate: 28, Baseline: 20, StdDev: 2, Warm: true}, false},
+		{"above the deviation", ErrorRate{Rate: 29, Baseline: 20, StdDev: 2, Warm: true}, true},
+	}
+	tracker := NewErrorRateTracker(time.Minute, 4, 20, 30)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure, got := tracker.Spike(tt.rate)
+			if got != tt.want {
+				t.Fatalf("Spike() = %v, want %v", got, tt.want)
+			}
+			if got && failure.RuleID != errorRateSpikeRule.ID {
+				t.Errorf("Spike() rule = %q, want %q", failure.RuleID, errorRateSpikeRule.ID)
+			}
+		})
+	}
+}
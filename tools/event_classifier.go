@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// EventCategory groups Warning event reasons by the kind of problem they
+// report.
+type EventCategory string
+
+const (
+	EventCategoryScheduling EventCategory = "scheduling"
+	EventCategoryImage      EventCategory = "image"
+	EventCategoryVolume     EventCategory = "volume"
+	EventCategoryCrash      EventCategory = "crash"
+	EventCategoryProbe      EventCategory = "probe"
+	EventCategorySandbox    EventCategory = "sandbox"
+	EventCategoryResources  EventCategory = "resources"
+	EventCategoryNode       EventCategory = "node"
+	EventCategoryWorkload   EventCategory = "workload"
+	EventCategoryOther      EventCategory = "other"
+)
+
+var eventReasonCategories = map[string]EventCategory{
+	"FailedScheduling":          EventCategoryScheduling,
+	"NotTriggerScaleUp":         EventCategoryScheduling,
+	"Preempted":                 EventCategoryScheduling,
+	"ErrImagePull":              EventCategoryImage,
+	"ImagePullBackOff":          EventCategoryImage,
+	"ErrImageNeverPull":         EventCategoryImage,
+	"InspectFailed":             EventCategoryImage,
+	"FailedMount":               EventCategoryVolume,
+	"FailedAttachVolume":        EventCategoryVolume,
+	"FailedMapVolume":           EventCategoryVolume,
+	"VolumeResizeFailed":        EventCategoryVolume,
+	"ProvisioningFailed":        EventCategoryVolume,
+	"BackOff":                   EventCategoryCrash,
+	"CrashLoopBackOff":          EventCategoryCrash,
+	"Unhealthy":                 EventCategoryProbe,
+	"ProbeWarning":              EventCategoryProbe,
+	"FailedCreatePodSandBox":    EventCategorySandbox,
+	"FailedKillPod":             EventCategorySandbox,
+	"FailedCreatePodContainer":  EventCategorySandbox,
+	"FailedPostStartHook":       EventCategorySandbox,
+	"FailedPreStopHook":         EventCategorySandbox,
+	"NetworkNotReady":           EventCategorySandbox,
+	"Evicted":                   EventCategoryResources,
+	"OOMKilling":                EventCategoryResources,
+	"SystemOOM":                 EventCategoryResources,
+	"EvictionThresholdMet":      EventCategoryResources,
+	"FreeDiskSpaceFailed":       EventCategoryResources,
+	"ExceededGracePeriod":       EventCategoryResources,
+	"NodeNotReady":              EventCategoryNode,
+	"Rebooted":                  EventCategoryNode,
+	"NodeHasDiskPressure":       EventCategoryNode,
+	"NodeHasInsufficientMemory": EventCategoryNode,
+	"FailedCreate":              EventCategoryWorkload,
+	"FailedDelete":              EventCategoryWorkload,
+	"BackoffLimitExceeded":      EventCategoryWorkload,
+	"DeadlineExceeded":          EventCategoryWorkload,
+	"ProgressDeadlineExceeded":  EventCategoryWorkload,
+}
+
+// ClassifyEvent maps a Warning event to a category by its reason. The kubelet
+// uses the generic reasons "Failed" and "BackOff" for both image pulls and
+// containers, so those are told apart by their message.
+func ClassifyEvent(reason, message string) EventCategory {
+	lower := strings.ToLower(message)
+	switch reason {
+	case "Failed":
+		if strings.Contains(lower, "image") {
+			return EventCategoryImage
+		}
+		return EventCategoryCrash
+	case "BackOff":
+		if strings.Contains(lower, "pulling image") {
+			return EventCategoryImage
+		}
+	}
+	if category, ok := eventReasonCategories[reason]; ok {
+		return category
+	}
+	return EventCategoryOther
+}
+
+// IsWarningEvent reports whether an event is a failure signal.
+func IsWarningEvent(event *corev1.Event) bool {
+	return event.Type == corev1.EventTypeWarning
+}
+
+// EventContainerName returns the container an event refers to, taken from a
+// field path such as "spec.containers{app}", or "" for pod-level events.
+func EventContainerName(event *corev1.Event) string {
+	fieldPath := event.InvolvedObject.FieldPath
+	start := strings.Index(fieldPath, "{")
+	if start < 0 || !strings.HasSuffix(fieldPath, "}") {
+		return ""
+	}
+	return fieldPath[start+1 : len(fieldPath)-1]
+}
+
+// EventLastSeen returns when an event was last observed, whichever of the
+// legacy and events.k8s.io timestamps the emitter filled in.
+func EventLastSeen(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// EventFirstSeen returns when an event was first observed.
+func EventFirstSeen(event *corev1.Event) time.Time {
+	switch {
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// EventCount returns how many times an event has been observed.
+func EventCount(event *corev1.Event) int32 {
+	if event.Series != nil && event.Series.Count > 0 {
+		return event.Series.Count
+	}
+	if event.Count > 0 {
+		return event.Count
+	}
+	return 1
+}
@@ -0,0 +1,298 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Exception is the exception or panic a failure's log event reports,
+// extracted from a Go panic, a Java stack trace, a Python traceback or a
+// Node.js error.
+type Exception struct {
+	Language string `json:"language"`
+	Type     string `json:"type"`
+	Message  string `json:"message,omitempty"`
+	// Frame is the top stack frame in application code, skipping the
+	// runtime, the standard library and dependencies
+	Frame string `json:"frame,omitempty"`
+	// RootCause is the innermost exception of a chain, as "Type: message"
+	RootCause string `json:"root_cause,omitempty"`
+}
+
+func (e *Exception) String() string {
+	s := e.Type
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.Frame != "" {
+		s += " at " + e.Frame
+	}
+	if e.RootCause != "" {
+		s += ", caused by " + e.RootCause
+	}
+	return s
+}
+
+var (
+	goPanicLineRe      = regexp.MustCompile(`^(panic|fatal error|SIG[A-Z]+): (.*?)( \[recovered\])?$`)
+	goGoroutineRe      = regexp.MustCompile(`^goroutine \d+ \[([^\]]+)\]:$`)
+	goFuncLineRe       = regexp.MustCompile(`^((?:[\w.-]+/)*[\w-]+)\.(\S+?)\(.*\)$`)
+	goFileLineRe       = regexp.MustCompile(`^\t(\S+:\d+)`)
+	javaThrowableRe    = regexp.MustCompile(`((?:[a-zA-Z_$][\w$]*\.)+[\w$]*(?:Exception|Error|Throwable))(?::\s*(.*))?$`)
+	javaFrameRe        = regexp.MustCompile(`^\s+at ((?:[\w$]+\.)+[\w$<>]+)\(([^)]*)\)`)
+	pyFrameRe          = regexp.MustCompile(`^\s+File "([^"]+)", line (\d+), in (\S+)`)
+	pyExceptionLineRe  = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s*(.*))?$`)
+	nodeErrorRe        = regexp.MustCompile(`^(?:Uncaught |\(node:\d+\) UnhandledPromiseRejectionWarning: )?((?:[A-Z]\w*)?(?:Error|Exception))(?: \[\w+\])?(?::\s*(.*))?$`)
+	nodeRejectionRe    = regexp.MustCompile(`^\[UnhandledPromiseRejection: .*reason "(.*)"\.\]`)
+	nodeFrameRe        = regexp.MustCompile(`^\s+at (?:async )?(?:(.+?) \()?([^()\s]+:\d+:\d+)\)?$`)
+	nodeCauseRe        = regexp.MustCompile(`^\s+\[cause\]: ((?:[A-Z]\w*)?(?:Error|Exception))(?::\s*(.*))?`)
+	javaLibraryPackage = regexp.MustCompile(`^(java|javax|jdk|sun|com\.sun|kotlin|kotlinx|scala|groovy|org\.springframework|org\.apache|org\.hibernate|org\.eclipse|io\.netty|reactor|io\.grpc|com\.fasterxml|com\.google|okhttp3|feign|io\.micrometer)\.`)
+)
+
+// ExtractException returns the exception reported by an event's lines, or
+// nil when they hold none.
+func ExtractException(lines []string) *Exception {
+	for _, extract := range []func([]string) *Exception{extractGoPanic, extractPythonTraceback, extractJavaException, extractNodeError} {
+		if exception := extract(lines); exception != nil {
+			return exception
+		}
+	}
+	return nil
+}
+
+// extractGoPanic reads a panic, a fatal error or a goroutine dump and the
+// stack of the first goroutine, where a function line is followed by its
+// file.
+func extractGoPanic(lines []string) *Exception {
+	var exception *Exception
+	for i, line := range lines {
+		if exception == nil {
+			if m := goPanicLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				exception = &Exception{Language: "go", Type: m[1], Message: m[2]}
+				if message, ok := strings.CutPrefix(m[2], "runtime error: "); ok {
+					exception.Type, exception.Message = "runtime error", message
+				}
+			} else if m := goGoroutineRe.FindStringSubmatch(line); m != nil && i == 0 {
+				exception = &Exception{Language: "go", Type: "goroutine dump", Message: m[1]}
+			}
+			continue
+		}
+		m := goFuncLineRe.FindStringSubmatch(line)
+		if m == nil || !isGoApplicationPackage(m[1]) {
+			continue
+		}
+		exception.Frame = m[1] + "." + m[2]
+		if i+1 < len(lines) {
+			if file := goFileLineRe.FindStringSubmatch(lines[i+1]); file != nil {
+				exception.Frame += " (" + file[1] + ")"
+			}
+		}
+		break
+	}
+	return exception
+}
+
+// isGoApplicationPackage reports whether a package is the application's:
+// main or a module path, as opposed to the runtime and standard library
+// whose import paths have no dot in their first element.
+func isGoApplicationPackage(pkg string) bool {
+	if pkg == "main" {
+		return true
+	}
+	first, _, _ := strings.Cut(pkg, "/")
+	return strings.Contains(first, ".") && !strings.HasPrefix(pkg, "golang.org/x/")
+}
+
+// extractJavaException reads the first throwable and its "Caused by" chain.
+// The top application frame is taken from the root cause, where the failure
+// started, when one of its frames is in the application's packages, named
+// after the outermost throwable's top frame; otherwise from the outermost
+// throwable. A throwable's name without a stack trace is too weak a sign,
+// e.g. for Python exception names.
+func extractJavaException(lines []string) *Exception {
+	var exception *Exception
+	var frame string
+	var causeFrames []string
+	inCause, hasStack := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if m := javaFrameRe.FindStringSubmatch(line); m != nil {
+			if exception == nil {
+				continue
+			}
+			hasStack = true
+			if javaLibraryPackage.MatchString(m[1]) {
+				continue
+			}
+			location := m[1] + " (" + m[2] + ")"
+			if !inCause && frame == "" {
+				frame = location
+			} else if inCause {
+				causeFrames = append(causeFrames, location)
+			}
+			continue
+		}
+		if cause, ok := strings.CutPrefix(trimmed, "Caused by: "); ok && exception != nil {
+			if m := javaThrowableRe.FindStringSubmatch(cause); m != nil {
+				exception.RootCause = joinTypeMessage(m[1], m[2])
+				inCause, causeFrames, hasStack = true, nil, true
+			}
+			continue
+		}
+		if exception == nil {
+			if m := javaThrowableRe.FindStringSubmatch(trimmed); m != nil {
+				exception = &Exception{Language: "java", Type: m[1], Message: m[2]}
+			}
+		}
+	}
+	if exception == nil || !hasStack {
+		return nil
+	}
+	exception.Frame = frame
+	for _, causeFrame := range causeFrames {
+		if frame == "" || strings.HasPrefix(causeFrame, javaApplicationPrefix(frame)) {
+			exception.Frame = causeFrame
+			break
+		}
+	}
+	return exception
+}
+
+// javaApplicationPrefix returns the first two package names of a frame, e.g.
+// "com.acme." for com.acme.shop.OrderService.process.
+func javaApplicationPrefix(frame string) string {
+	parts := strings.SplitN(frame, ".", 3)
+	if len(parts) < 3 {
+		return frame
+	}
+	return parts[0] + "." + parts[1] + "."
+}
+
+// extractPythonTraceback reads a traceback. Python prints chained
+// exceptions cause first, so the last exception is the one raised and the
+// first one the root cause; frames are listed most recent call last.
+func extractPythonTraceback(lines []string) *Exception {
+	var exceptions []string
+	var frame string
+	inTraceback := false
+	for _, line := range lines {
+		switch {
+		case pyTracebackRe.MatchString(line):
+			inTraceback = true
+			frame = ""
+		case !inTraceback:
+		case pyFrameRe.MatchString(line):
+			m := pyFrameRe.FindStringSubmatch(line)
+			if isPythonApplicationFile(m[1]) {
+				frame = fmt.Sprintf("%s (%s:%s)", m[3], m[1], m[2])
+			}
+		case strings.TrimSpace(line) == "" || indentedLineRe.MatchString(line):
+		case pyExceptionLineRe.MatchString(line):
+			exceptions = append(exceptions, line)
+			inTraceback = false
+		}
+	}
+	if len(exceptions) == 0 {
+		return nil
+	}
+	m := pyExceptionLineRe.FindStringSubmatch(exceptions[len(exceptions)-1])
+	exception := &Exception{Language: "python", Type: m[1], Message: m[2], Frame: frame}
+	if len(exceptions) > 1 {
+		root := pyExceptionLineRe.FindStringSubmatch(exceptions[0])
+		exception.RootCause = joinTypeMessage(root[1], root[2])
+	}
+	return exception
+}
+
+func isPythonApplicationFile(path string) bool {
+	return !strings.Contains(path, "site-packages") && !strings.Contains(path, "dist-packages") &&
+		!strings.HasPrefix(path, "/usr/lib/python") && !strings.HasPrefix(path, "/usr/local/lib/python") &&
+		!strings.HasPrefix(path, "<")
+}
+
+// extractNodeError reads an uncaught error or unhandled promise rejection
+// with its stack and "[cause]" chain.
+func extractNodeError(lines []string) *Exception {
+	var exception *Exception
+	for _, line := range lines {
+		if exception == nil {
+			if m := nodeRejectionRe.FindStringSubmatch(line); m != nil {
+				exception = &Exception{Language: "node", Type: "UnhandledPromiseRejection", Message: m[1]}
+			} else if m := nodeErrorRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				exception = &Exception{Language: "node", Type: m[1], Message: m[2]}
+			}
+			continue
+		}
+		if m := nodeCauseRe.FindStringSubmatch(line); m != nil {
+			exception.RootCause = joinTypeMessage(m[1], m[2])
+			continue
+		}
+		if m := nodeFrameRe.FindStringSubmatch(line); m != nil && exception.Frame == "" && isNodeApplicationFile(m[2]) {
+			exception.Frame = m[2]
+			if m[1] != "" {
+				exception.Frame = m[1] + " (" + m[2] + ")"
+			}
+		}
+	}
+	// A bare "Error: ..." line is any log line; a Node error has a stack
+	if exception != nil && exception.Frame == "" && exception.Type != "UnhandledPromiseRejection" && !hasNodeFrame(lines) {
+		return nil
+	}
+	return exception
+}
+
+func isNodeApplicationFile(location string) bool {
+	return !strings.HasPrefix(location, "node:") && !strings.HasPrefix(location, "internal/") &&
+		!strings.Contains(location, "node_modules")
+}
+
+func hasNodeFrame(lines []string) bool {
+	for _, line := range lines {
+		if nodeFrameRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTypeMessage(typ, message string) string {
+	if message == "" {
+		return typ
+	}
+	return typ + ": " + message
+}
+
+// searchTermRe keeps the words of a message worth searching for.
+var searchTermRe = regexp.MustCompile(`^[A-Za-z][A-Za-z_-]{3,}$`)
+
+// SearchTerms returns terms to search issue trackers with: the exception's
+// short type name, the root cause's when it differs, and a few words of the
+// root cause's or exception's message.
+func (e *Exception) SearchTerms() string {
+	terms := []string{shortTypeName(e.Type)}
+	message := e.Message
+	if e.RootCause != "" {
+		typ, rootMessage, _ := strings.Cut(e.RootCause, ": ")
+		if short := shortTypeName(typ); short != terms[0] {
+			terms = append(terms, short)
+		}
+		message = rootMessage
+	}
+	words := 0
+	for _, word := range strings.Fields(FailureSignature(message)) {
+		word = strings.Trim(word, `.,;:'"()[]{}`)
+		if words < 3 && searchTermRe.MatchString(word) {
+			terms = append(terms, word)
+			words++
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func shortTypeName(typ string) string {
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		return typ[i+1:]
+	}
+	return typ
+}
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractException(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want *Exception
+	}{
+		{
+			name: "go panic",
+			log: `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]
+
+goroutine 1 [running]:
+net/http.(*conn).serve(0xc000120000)
+	/usr/local/go/src/net/http/server.go:1850 +0x1a
+github.com/acme/shop/internal/cart.(*Service).Checkout(0x0, {0x0, 0x0})
+	/src/internal/cart/service.go:42 +0x1d
+main.main()
+	/src/main.go:10 +0x25`,
+			want: &Exception{Language: "go", Type: "runtime error", Message: "invalid memory address or nil pointer dereference",
+				Frame: "github.com/acme/shop/internal/cart.(*Service).Checkout (/src/internal/cart/service.go:42)"},
+		},
+		{
+			name: "go recovered panic",
+			log: `panic: order 42 not found [recovered]
+goroutine 7 [running]:
+main.handle()
+	/src/main.go:20 +0x3b`,
+			want: &Exception{Language: "go", Type: "panic", Message: "order 42 not found", Frame: "main.handle (/src/main.go:20)"},
+		},
+		{
+			name: "go fatal error",
+			log: `fatal error: concurrent map writes
+
+goroutine 12 [running]:
+runtime.throw({0x6b2e1f, 0x15})
+	/usr/local/go/src/runtime/panic.go:1047 +0x5d
+main.(*cache).set(...)
+	/src/cache.go:31`,
+			want: &Exception{Language: "go", Type: "fatal error", Message: "concurrent map writes", Frame: "main.(*cache).set (/src/cache.go:31)"},
+		},
+		{
+			name: "go goroutine dump",
+			log: `goroutine 1 [chan receive, 10 minutes]:
+golang.org/x/sync/errgroup.(*Group).Wait(0xc0000a4000)
+	/go/pkg/mod/golang.org/x/sync/errgroup/errgroup.go:56 +0x25
+main.run()
+	/src/main.go:77 +0x4c`,
+			want: &Exception{Language: "go", Type: "goroutine dump", Message: "chan receive, 10 minutes", Frame: "main.run (/src/main.go:77)"},
+		},
+		{
+			name: "go goroutine header after the first line",
+			log: `level=info msg="dumping state"
+goroutine 1 [running]:
+main.main()`,
+			want: nil,
+		},
+		{
+			name: "java caused by",
+			log: `org.springframework.web.util.NestedServletException: Request processing failed
+	at org.springframework.web.servlet.FrameworkServlet.processRequest(FrameworkServlet.java:1014)
+	at com.acme.shop.web.OrderController.create(OrderController.java:51)
+Caused by: java.lang.IllegalStateException: stock is negative
+	at java.base/java.util.Objects.requireNonNull(Objects.java:209)
+	at com.acme.shop.inventory.Stock.reserve(Stock.java:88)
+	at com.acme.shop.web.OrderController.create(OrderController.java:49)
+	... 12 more`,
+			want: &Exception{Language: "java", Type: "org.springframework.web.util.NestedServletException", Message: "Request processing failed",
+				Frame: "com.acme.shop.inventory.Stock.reserve (Stock.java:88)", RootCause: "java.lang.IllegalStateException: stock is negative"},
+		},
+		{
+			name: "java nested caused by",
+			log: `java.lang.RuntimeException: checkout failed
+	at com.acme.shop.Checkout.run(Checkout.java:30)
+Caused by: javax.persistence.PersistenceException: could not execute statement
+	... 8 more
+Caused by: java.sql.SQLTimeoutException: query timed out
+	at org.postgresql.jdbc.PgStatement.execute(PgStatement.java:120)
+	... 15 more`,
+			want: &Exception{Language: "java", Type: "java.lang.RuntimeException", Message: "checkout failed",
+				Frame: "com.acme.shop.Checkout.run (Checkout.java:30)", RootCause: "java.sql.SQLTimeoutException: query timed out"},
+		},
+		{
+			name: "java cause outside the application packages",
+			log: `java.lang.IllegalArgumentException: bad id
+	at com.acme.shop.Ids.parse(Ids.java:12)
+Caused by: java.lang.NumberFormatException: For input string: "x"
+	at org.example.lib.Parser.parse(Parser.java:5)
+	... 3 more`,
+			want: &Exception{Language: "java", Type: "java.lang.IllegalArgumentException", Message: "bad id",
+				Frame: "com.acme.shop.Ids.parse (Ids.java:12)", RootCause: `java.lang.NumberFormatException: For input string: "x"`},
+		},
+		{
+			name: "java throwable without a stack",
+			log:  `WARN retrying after java.io.IOException: connection reset`,
+			want: nil,
+		},
+		{
+			name: "python traceback",
+			log: `Traceback (most recent call last):
+  File "/usr/local/lib/python3.12/site-packages/flask/app.py", line 1473, in wsgi_app
+    response = self.full_dispatch_request()
+  File "/app/shop/views.py", line 27, in checkout
+    total = cart.total()
+  File "/app/shop/cart.py", line 12, in total
+    return sum(item.price for item in self.items)
+TypeError: unsupported operand type(s) for +: 'int' and 'NoneType'`,
+			want: &Exception{Language: "python", Type: "TypeError", Message: "unsupported operand type(s) for +: 'int' and 'NoneType'",
+				Frame: "total (/app/shop/cart.py:12)"},
+		},
+		{
+			name: "python chained traceback",
+			log: `Traceback (most recent call last):
+  File "/app/shop/db.py", line 40, in connect
+    sock.connect(addr)
+ConnectionRefusedError: [Errno 111] Connection refused
+
+The above exception was the direct cause of the following exception:
+
+Traceback (most recent call last):
+  File "/app/shop/main.py", line 9, in <module>
+    db.connect()
+  File "/app/shop/db.py", line 42, in connect
+    raise DatabaseError("database unavailable") from exc
+shop.db.DatabaseError: database unavailable`,
+			want: &Exception{Language: "python", Type: "shop.db.DatabaseError", Message: "database unavailable",
+				Frame: "connect (/app/shop/db.py:42)", RootCause: "ConnectionRefusedError: [Errno 111] Connection refused"},
+		},
+		{
+			name: "python traceback in library code only",
+			log: `Traceback (most recent call last):
+  File "<frozen runpy>", line 198, in _run_module_as_main
+  File "/usr/lib/python3.11/json/decoder.py", line 355, in raw_decode
+KeyboardInterrupt`,
+			want: &Exception{Language: "python", Type: "KeyboardInterrupt"},
+		},
+		{
+			name: "node uncaught error",
+			log: `TypeError: Cannot read properties of undefined (reading 'id')
+    at Object.<anonymous> (node:internal/modules/cjs/loader:1105:14)
+    at getUser (/app/node_modules/orm/lib/query.js:88:11)
+    at async OrderService.create (/app/src/orders/service.js:31:17)
+    at async /app/src/server.js:12:5`,
+			want: &Exception{Language: "node", Type: "TypeError", Message: "Cannot read properties of undefined (reading 'id')",
+				Frame: "OrderService.create (/app/src/orders/service.js:31:17)"},
+		},
+		{
+			name: "node anonymous frame",
+			log: `Uncaught RangeError: Invalid array length
+    at /app/src/report.js:7:15`,
+			want: &Exception{Language: "node", Type: "RangeError", Message: "Invalid array length", Frame: "/app/src/report.js:7:15"},
+		},
+		{
+			name: "node error with a cause",
+			log: `Error: failed to load config
+    at loadConfig (/app/src/config.js:20:11)
+  [cause]: SyntaxError: Unexpected token } in JSON at position 42
+    at JSON.parse (<anonymous>)`,
+			want: &Exception{Language: "node", Type: "Error", Message: "failed to load config",
+				Frame: "loadConfig (/app/src/config.js:20:11)", RootCause: "SyntaxError: Unexpected token } in JSON at position 42"},
+		},
+		{
+			name: "node unhandled promise rejection",
+			log:  `[UnhandledPromiseRejection: This error originated either by throwing inside of an async function without a catch block, or by rejecting a promise which was not handled with .catch(). The promise rejected with the reason "timeout".] {`,
+			want: &Exception{Language: "node", Type: "UnhandledPromiseRejection", Message: "timeout"},
+		},
+		{
+			name: "node error without a stack",
+			log:  `Error: something happened`,
+			want: nil,
+		},
+		{
+			name: "plain log line",
+			log:  `ERROR connection refused to 10.0.0.2:5432`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractException(strings.Split(tt.log, "\n"))
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Fatalf("ExtractException() = %v, want %v", got, tt.want)
+			case *got != *tt.want:
+				t.Errorf("ExtractException() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestExceptionSearchTerms(t *testing.T) {
+	tests := []struct {
+		name      string
+		exception Exception
+		want      string
+	}{
+		{
+			name:      "message words",
+			exception: Exception{Type: "runtime error", Message: "invalid memory address or nil pointer dereference"},
+			want:      "runtime error invalid memory address",
+		},
+		{
+			name: "root cause",
+			exception: Exception{Type: "org.springframework.web.util.NestedServletException", Message: "Request processing failed",
+				RootCause: "java.sql.SQLTimeoutException: query timed out after 30s"},
+			want: "NestedServletException SQLTimeoutException query timed after",
+		},
+		{
+			name:      "root cause of the same type",
+			exception: Exception{Type: "Error", Message: "outer", RootCause: "Error: connect ECONNREFUSED 10.0.0.2:5432"},
+			want:      "Error connect ECONNREFUSED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.exception.SearchTerms(); got != tt.want {
+				t.Errorf("SearchTerms() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// signalNames names the signals a container is commonly terminated by; an
+// exit code of 128+N means the process died of signal N.
+var signalNames = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+// ExitInterpretation explains a container termination: what the exit code,
+// signal and reason mean and, checked against the container's resource
+// limits, what most likely caused it.
+type ExitInterpretation struct {
+	Container string `json:"container"`
+	ExitCode  int32  `json:"exit_code"`
+	Signal    string `json:"signal,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Previous is set for the last terminated instance of a restarted
+	// container
+	Previous bool   `json:"previous,omitempty"`
+	Meaning  string `json:"meaning"`
+	// Limits is what the container's resource limits say about the cause
+	Limits string `json:"limits,omitempty"`
+	Advice string `json:"advice"`
+}
+
+func (e ExitInterpretation) String() string {
+	code := fmt.Sprintf("exit code %d", e.ExitCode)
+	if e.Signal != "" {
+		code += ", " + e.Signal
+	}
+	if e.Reason != "" && e.Reason != "Error" {
+		code += ", " + e.Reason
+	}
+	if e.Previous {
+		code += ", previous instance"
+	}
+	s := fmt.Sprintf("%s (%s): %s.", e.Container, code, e.Meaning)
+	if e.Limits != "" {
+		s += " " + e.Limits + "."
+	}
+	return s + " Check: " + e.Advice
+}
+
+// InterpretTermination explains a terminated container state, using the
+// container's resources and the pod's termination grace period to narrow
+// down the cause.
+func InterpretTermination(container corev1.Container, terminated *corev1.ContainerStateTerminated, gracePeriod time.Duration, previous bool) ExitInterpretation {
+	interpretation := ExitInterpretation{
+		Container: container.Name,
+		ExitCode:  terminated.ExitCode,
+		Reason:    terminated.Reason,
+		Previous:  previous,
+	}
+	signal := terminated.Signal
+	if signal == 0 && terminated.ExitCode > 128 && terminated.ExitCode < 160 {
+		signal = terminated.ExitCode - 128
+	}
+	if signal != 0 {
+		interpretation.Signal = signalNames[signal]
+		if interpretation.Signal == "" {
+			interpretation.Signal = fmt.Sprintf("signal %d", signal)
+		}
+	}
+
+	memoryLimit := container.Resources.Limits.Memory()
+	hasMemoryLimit := !memoryLimit.IsZero()
+	switch {
+	case terminated.Reason == "OOMKilled":
+		interpretation.Meaning = "the kernel's OOM killer ended the process because the container's cgroup ran out of memory"
+		if hasMemoryLimit {
+			interpretation.Limits = fmt.Sprintf("The container used up its memory limit of %s", memoryLimit)
+		} else {
+			interpretation.Limits = "No memory limit is set, so the node itself ran out of memory"
+		}
+		interpretation.Advice = "memory usage against the limit; raise the limit or fix the leak, and size runtime heaps (e.g. -XX:MaxRAMPercentage, GOMEMLIMIT) below it"
+	case terminated.Reason == "StartError" || terminated.Reason == "ContainerCannotRun":
+		interpretation.Meaning = "the container runtime could not start the command"
+		interpretation.Advice = "that the command and entrypoint exist in the image and are executable, and the runtime's message"
+	case signal == 9:
+		interpretation.Meaning = "the process was killed with SIGKILL"
+		if hasMemoryLimit {
+			interpretation.Limits = fmt.Sprintf("Without an OOMKilled reason, a child process may have been OOM-killed near the memory limit of %s", memoryLimit)
+		} else {
+			interpretation.Limits = "No memory limit is set, so this was not the container's own cgroup OOM kill"
+		}
+		interpretation.Advice = fmt.Sprintf("whether the process ignores SIGTERM and was killed after the %s termination grace period, liveness probe restarts in the pod's events, and node memory pressure", gracePeriod)
+	case signal == 15:
+		interpretation.Meaning = "the process died of SIGTERM, the stop signal sent on rollouts, scale-downs, evictions and liveness probe restarts, without handling it"
+		interpretation.Advice = "the pod's events for why it was stopped, and handle SIGTERM to shut down cleanly and exit with 0"
+	case signal == 11:
+		interpretation.Meaning = "segmentation fault: invalid memory access in native code"
+		interpretation.Advice = "native libraries, cgo or JNI code and C extensions, and that the image's binaries match its libc (e.g. glibc binaries on Alpine)"
+	case signal == 6:
+		interpretation.Meaning = "the process aborted itself, e.g. a failed assertion, an uncaught C++ exception or a fatal runtime error"
+		interpretation.Advice = "the last log lines before the abort and any core dump or hs_err file"
+	case signal == 7 || signal == 4:
+		interpretation.Meaning = "the process ran an invalid instruction or memory access, often a binary built for another CPU or a truncated file"
+		interpretation.Advice = "the image's architecture against the node's and the integrity of mounted files"
+	case signal != 0:
+		interpretation.Meaning = "the process was terminated by " + interpretation.Signal
+		interpretation.Advice = "what sends the signal, e.g. a supervisor process or a preStop hook"
+	case terminated.ExitCode == 1:
+		interpretation.Meaning = "the application exited with a general error"
+		interpretation.Advice = "the last log lines of the instance for the error it reported"
+	case terminated.ExitCode == 2:
+		interpretation.Meaning = "a Go program panicked, or a command was called with invalid arguments"
+		interpretation.Advice = "the panic's stack trace in the logs, or the container's command and args"
+	case terminated.ExitCode == 126:
+		interpretation.Meaning = "the command was found but cannot be executed"
+		interpretation.Advice = "the file's execute permission, its shebang interpreter and the image's architecture"
+	case terminated.ExitCode == 127:
+		interpretation.Meaning = "the command was not found: the binary is missing from the image or the PATH"
+		interpretation.Advice = "the command and args against the image contents, e.g. with a shell in the image or its Dockerfile"
+	case terminated.ExitCode == 255:
+		interpretation.Meaning = "the process exited with -1, often a fatal startup error such as an exec format error"
+		interpretation.Advice = "the first log lines of the instance and the image's architecture"
+	default:
+		interpretation.Meaning = fmt.Sprintf("the application exited with its own error code %d", terminated.ExitCode)
+		interpretation.Advice = "the application's documentation for the exit code and its last log lines"
+	}
+	return interpretation
+}
+
+// InterpretPodTerminations explains the failed terminations of the pod's
+// containers, current ones and those of recently restarted containers.
+func InterpretPodTerminations(pod *corev1.Pod) []ExitInterpretation {
+	gracePeriod := time.Duration(corev1.DefaultTerminationGracePeriodSeconds) * time.Second
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		gracePeriod = time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	}
+	containers := make(map[string]corev1.Container)
+	for _, container := range pod.Spec.InitContainers {
+		containers[container.Name] = container
+	}
+	for _, container := range pod.Spec.Containers {
+		containers[container.Name] = container
+	}
+
+	var interpretations []ExitInterpretation
+	for _, result := range PodContainerStatuses(pod) {
+		status := result.Status
+		container, ok := containers[status.Name]
+		if !ok {
+			container = corev1.Container{Name: status.Name}
+		}
+		if failedTermination(status.State.Terminated) {
+			interpretations = append(interpretations, InterpretTermination(container, status.State.Terminated, gracePeriod, false))
+		}
+		if last := status.LastTerminationState.Terminated; failedTermination(last) &&
+			(status.State.Running == nil || time.Since(last.FinishedAt.Time) < recentTermination) {
+			interpretations = append(interpretations, InterpretTermination(container, last, gracePeriod, true))
+		}
+	}
+	return interpretations
+}
+
+func failedTermination(terminated *corev1.ContainerStateTerminated) bool {
+	return terminated != nil && (terminated.ExitCode != 0 || terminated.Reason == "OOMKilled")
+}
+
+// exitInterpretationHeader starts the list of DescribeTerminations, where
+// the fallback recommendations look for it.
+const exitInterpretationHeader = "Exit codes:"
+
+// DescribeTerminations renders interpretations as a list for the LLM context.
+func DescribeTerminations(interpretations []ExitInterpretation) string {
+	if len(interpretations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(exitInterpretationHeader + "\n")
+	for _, interpretation := range interpretations {
+		fmt.Fprintf(&b, "- %s\n", interpretation)
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestInterpretTermination(t *testing.T) {
+	limited := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+	}}
+	unlimited := corev1.Container{Name: "app"}
+	tests := []struct {
+		name        string
+		container   corev1.Container
+		terminated  corev1.ContainerStateTerminated
+		wantSignal  string
+		wantMeaning string // substring
+		wantLimits  string // substring, "" for none
+	}{
+		{
+			name:        "OOMKilled at the limit",
+			container:   limited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"},
+			wantSignal:  "SIGKILL",
+			wantMeaning: "OOM killer",
+			wantLimits:  "memory limit of 256Mi",
+		},
+		{
+			name:        "OOMKilled without a limit",
+			container:   unlimited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"},
+			wantSignal:  "SIGKILL",
+			wantMeaning: "OOM killer",
+			wantLimits:  "node itself ran out of memory",
+		},
+		{
+			name:        "137 without OOMKilled",
+			container:   limited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 137, Reason: "Error"},
+			wantSignal:  "SIGKILL",
+			wantMeaning: "killed with SIGKILL",
+			wantLimits:  "child process may have been OOM-killed",
+		},
+		{
+			name:        "137 without a limit",
+			container:   unlimited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 137, Reason: "Error"},
+			wantSignal:  "SIGKILL",
+			wantMeaning: "killed with SIGKILL",
+			wantLimits:  "not the container's own cgroup OOM kill",
+		},
+		{
+			name:        "143",
+			container:   limited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 143, Reason: "Error"},
+			wantSignal:  "SIGTERM",
+			wantMeaning: "SIGTERM",
+		},
+		{
+			name:        "139",
+			container:   limited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 139, Reason: "Error"},
+			wantSignal:  "SIGSEGV",
+			wantMeaning: "segmentation fault",
+		},
+		{
+			name:        "signal from the runtime",
+			container:   unlimited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 0, Signal: 6},
+			wantSignal:  "SIGABRT",
+			wantMeaning: "aborted itself",
+		},
+		{
+			name:        "application error code",
+			container:   unlimited,
+			terminated:  corev1.ContainerStateTerminated{ExitCode: 3, Reason: "Error"},
+			wantMeaning: "its own error code 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := InterpretTermination(tt.container, &tt.terminated, 30*time.Second, false)
+			if got.Signal != tt.wantSignal || got.ExitCode != tt.terminated.ExitCode {
+				t.Errorf("InterpretTermination() exit code %d, signal %q, want %d, %q", got.ExitCode, got.Signal, tt.terminated.ExitCode, tt.wantSignal)
+			}
+			if !strings.Contains(got.Meaning, tt.wantMeaning) {
+				t.Errorf("InterpretTermination() meaning = %q, want it to mention %q", got.Meaning, tt.wantMeaning)
+			}
+			if (tt.wantLimits == "") != (got.Limits == "") || !strings.Contains(got.Limits, tt.wantLimits) {
+				t.Errorf("InterpretTermination() limits = %q, want %q", got.Limits, tt.wantLimits)
+			}
+			if got.Advice == "" {
+				t.Error("InterpretTermination() gives no advice")
+			}
+		})
+	}
+}
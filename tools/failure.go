@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Failure is one detected failure with when and how often it occurred.
+// Occurrences of the same rule with the same signature within one analysis
+// are reported once with a count and a few example messages; the message,
+// line, line number and context are those of the first occurrence. Failures
+// found by a rule carry the rule's classification.
+type Failure struct {
+	Message string `json:"message"`
+	// Signature is the message with variable parts such as IPs and numbers
+	// normalized; see FailureSignature
+	Signature string `json:"signature,omitempty"`
+	// Fingerprint identifies the failure across pods and scans; see
+	// FailureFingerprint
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Examples    []string `json:"examples,omitempty"`
+	RuleID      string   `json:"rule_id,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Severity    Severity `json:"severity,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	// Exception is the exception or panic of the failure's stack trace
+	Exception *Exception `json:"exception,omitempty"`
+	// Level, Caller and Fields are parsed from a structured log line
+	Level  LogLevel          `json:"level,omitempty"`
+	Caller string            `json:"caller,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// RulesVersion is the version of the rule set that matched; see
+	// RuleSet.Version
+	RulesVersion string `json:"rules_version,omitempty"`
+	// Line is the full log line that matched, without its timestamp prefix
+	Line string `json:"line,omitempty"`
+	// LineNumber is 1-based within the analyzed logs, those of the
+	// previous instance for a Previous failure
+	LineNumber int `json:"line_number,omitempty"`
+	// Previous is set for failures found in the logs of the container's
+	// previous, terminated instance
+	Previous bool `json:"previous,omitempty"`
+	// Context holds the lines around Line, including the whole stack trace
+	// of a multiline event
+	Context   []string  `json:"context,omitempty"`
+	FirstSeen time.Time `json:"first_seen,omitzero"`
+	LastSeen  time.Time `json:"last_seen,omitzero"`
+	Count     int       `json:"count"`
+	// SuppressedBy explains which suppression or silence hid the failure
+	SuppressedBy string `json:"suppressed_by,omitempty"`
+}
+
+func (f Failure) String() string {
+	var details []string
+	if f.Severity != "" {
+		details = append(details, string(f.Severity))
+	}
+	switch {
+	case f.FirstSeen.IsZero():
+		if f.Count > 1 {
+			details = append(details, fmt.Sprintf("x%d", f.Count))
+		}
+	case f.Count > 1:
+		details = append(details, fmt.Sprintf("x%d, first seen %s, last seen %s", f.Count,
+			f.FirstSeen.UTC().Format(time.RFC3339), f.LastSeen.UTC().Format(time.RFC3339)))
+	default:
+		details = append(details, "at "+f.FirstSeen.UTC().Format(time.RFC3339))
+	}
+	if f.Previous {
+		details = append(details, "previous instance")
+	}
+	if f.Fingerprint != "" {
+		details = append(details, "fingerprint "+f.Fingerprint)
+	}
+	if len(details) == 0 {
+		return f.Message
+	}
+	return fmt.Sprintf("%s (%s)", f.Message, strings.Join(details, ", "))
+}
+
+// DescribeFailures renders failures for an LLM prompt: one block per failure
+// with its classification, where it occurred and the surrounding log lines.
+func DescribeFailures(failures []Failure) string {
+	var b strings.Builder
+	for i, f := range failures {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, f)
+		if f.RuleID != "" {
+			fmt.Fprintf(&b, "   Rule: %s (category %s, severity %s)\n", f.RuleID, f.Category, f.Severity)
+		} else if f.Category != "" {
+			fmt.Fprintf(&b, "   Category: %s\n", f.Category)
+		}
+		if f.LineNumber > 0 && f.Previous {
+			fmt.Fprintf(&b, "   Line %d of the previous instance's logs: %s\n", f.LineNumber, f.Line)
+		} else if f.LineNumber > 0 {
+			fmt.Fprintf(&b, "   Line %d: %s\n", f.LineNumber, f.Line)
+		}
+		if f.Exception != nil {
+			fmt.Fprintf(&b, "   Exception (%s): %s\n", f.Exception.Language, f.Exception)
+		}
+		if len(f.Examples) > 1 {
+			fmt.Fprintf(&b, "   Examples: %s\n", strings.Join(f.Examples, " | "))
+		}
+		if len(f.Context) > 0 {
+			b.WriteString("   Context:\n")
+			for _, line := range f.Context {
+				fmt.Fprintf(&b, "     %s\n", line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// FailureMessages returns the failures rendered with their times and counts.
+func FailureMessages(failures []Failure) []string {
+	messages := make([]string, len(failures))
+	for i, failure := range failures {
+		messages[i] = failure.String()
+	}
+	return messages
+}
+
+// failureAggregator merges occurrences of a failure by key, by default
+// their rule and signature, keeping the order in which they first occurred.
+type failureAggregator struct {
+	key      func(Failure) string
+	index    map[string]int
+	failures []Failure
+}
+
+// newFailure returns a single occurrence of a failure found at line
+// lineNumber, classified by rule when it is not nil.
+func newFailure(message, line string, lineNumber int, at time.Time, rule *Rule) Failure {
+	failure := Failure{
+		Message:    message,
+		Signature:  FailureSignature(message),
+		Examples:   []string{message},
+		Line:       line,
+		LineNumber: lineNumber,
+		FirstSeen:  at,
+		LastSeen:   at,
+		Count:      1,
+	}
+	if rule != nil {
+		failure.RuleID = rule.ID
+		failure.Category = rule.Category
+		failure.Severity = rule.Severity
+		failure.Remediation = rule.Remediation
+	}
+	return failure
+}
+
+// AggregateFailures merges the occurrences of each failure by rule and
+// signature into one failure with a count, first and last seen times and
+// examples.
+func AggregateFailures(occurrences []Failure) []Failure {
+	var failures failureAggregator
+	for _, occurrence := range occurrences {
+		failures.add(occurrence)
+	}
+	return failures.failures
+}
+
+// MergeFailures merges failures that were aggregated separately, such as
+// those of several pods, when key returns the same for them: counts add up,
+// and the first and last seen times and examples are combined.
+func MergeFailures(failures []Failure, key func(Failure) string) []Failure {
+	merged := failureAggregator{key: key}
+	for _, failure := range failures {
+		merged.add(failure)
+	}
+	return merged.failures
+}
+
+// add records an occurrence of a failure, or an aggregated failure, merging
+// it into an earlier one with the same key.
+func (a *failureAggregator) add(occurrence Failure) {
+	if a.index == nil {
+		a.index = make(map[string]int)
+	}
+	key := occurrence.RuleID + "|" + occurrence.Signature
+	if a.key != nil {
+		key = a.key(occurrence)
+	}
+	i, seen := a.index[key]
+	if !seen {
+		a.index[key] = len(a.failures)
+		occurrence.Examples = slices.Clone(occurrence.Examples)
+		a.failures = append(a.failures, occurrence)
+		return
+	}
+	failure := &a.failures[i]
+	failure.Count += occurrence.Count
+	for _, example := range occurrence.Examples {
+		if len(failure.Examples) < maxFailureExamples && !slices.Contains(failure.Examples, example) {
+			failure.Examples = append(failure.Examples, example)
+		}
+	}
+	if at := occurrence.FirstSeen; !at.IsZero() && (failure.FirstSeen.IsZero() || at.Before(failure.FirstSeen)) {
+		failure.FirstSeen = at
+	}
+	if occurrence.LastSeen.After(failure.LastSeen) {
+		failure.LastSeen = occurrence.LastSeen
+	}
+}
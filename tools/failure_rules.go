@@ -0,0 +1,468 @@
+package tools
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed rules/default.yaml
+var defaultRulesYAML []byte
+
+// Severity ranks how urgently a failure needs attention.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Rank orders severities from info (1) to critical (5); unknown severities
+// rank 0.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityLow:
+		return 2
+	case SeverityMedium:
+		return 3
+	case SeverityHigh:
+		return 4
+	case SeverityCritical:
+		return 5
+	}
+	return 0
+}
+
+// Rule is one declarative failure rule. Pattern and Suppress are
+// case-insensitive regular expressions: a log event is a failure when Pattern
+// matches it and none of the Suppress patterns do. A rule with a Level only
+// matches structured lines at or above it; without a Pattern it matches all
+// of them.
+type Rule struct {
+	ID          string   `json:"id"`
+	Pattern     string   `json:"pattern"`
+	Category    string   `json:"category"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description,omitempty"`
+	Suppress    []string `json:"suppress,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	// Field makes the rule match the value of this field of structured log
+	// lines, such as "status" or "component", instead of the log text
+	Field string `json:"field,omitempty"`
+	// Level is the lowest level of the structured lines the rule matches,
+	// such as "warn" or "error"
+	Level string `json:"level,omitempty"`
+	// Disabled turns off the built-in rule with the same ID
+	Disabled bool `json:"disabled,omitempty"`
+
+	re       *regexp.Regexp
+	suppress []*regexp.Regexp
+	minLevel LogLevel
+}
+
+type ruleFile struct {
+	Rules        []*Rule        `json:"rules"`
+	Suppressions []*Suppression `json:"suppressions,omitempty"`
+}
+
+// compile validates the rule and compiles its patterns.
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule without id")
+	}
+	if r.Disabled {
+		return nil
+	}
+	if r.Level != "" {
+		r.minLevel = ParseLevel(r.Level)
+		if r.minLevel == LevelUnknown {
+			return fmt.Errorf("rule %s: unknown level %q", r.ID, r.Level)
+		}
+	}
+	if r.Pattern == "" && (r.Level == "" || r.Field != "") {
+		return fmt.Errorf("rule %s: pattern is required", r.ID)
+	}
+	if r.Category == "" {
+		return fmt.Errorf("rule %s: category is required", r.ID)
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityMedium
+	}
+	if r.Severity.Rank() == 0 {
+		return fmt.Errorf("rule %s: unknown severity %q", r.ID, r.Severity)
+	}
+
+	if r.Pattern != "" {
+		re, err := regexp.Compile("(?i)" + r.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: invalid pattern: %w", r.ID, err)
+		}
+		r.re = re
+	}
+	r.suppress = make([]*regexp.Regexp, len(r.Suppress))
+	for i, pattern := range r.Suppress {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return fmt.Errorf("rule %s: invalid suppress pattern: %w", r.ID, err)
+		}
+		r.suppress[i] = re
+	}
+	return nil
+}
+
+// Match returns the text the rule's pattern matches in text, or "" when the
+// pattern does not match or a suppress pattern does. A rule without a
+// pattern matches no text.
+func (r *Rule) Match(text string) string {
+	if r.re == nil {
+		return ""
+	}
+	match := r.re.FindString(text)
+	if match == "" {
+		return ""
+	}
+	for _, re := range r.suppress {
+		if re.MatchString(text) {
+			return ""
+		}
+	}
+	return match
+}
+
+// matchesLevel reports whether a line at level is within the rule's level;
+// lines of unknown level, such as plain text, are only within rules without
+// one.
+func (r *Rule) matchesLevel(level LogLevel) bool {
+	return r.minLevel == LevelUnknown || level >= r.minLevel
+}
+
+// RuleSet is an ordered list of failure rules, where the first matching rule
+// classifies a log event, and the suppressions hiding known noise.
+type RuleSet struct {
+	rules        []*Rule
+	fieldRules   []FieldRule
+	levelRules   []*Rule
+	suppressions []*Suppression
+	version      string
+	sources      []string
+}
+
+// newRuleSet builds a rule set from merged rules, setting the field rules
+// and the rules matching on level alone apart from the ones matched against
+// the log text.
+func newRuleSet(rules []*Rule, suppressions []*Suppression, version string, sources []string) *RuleSet {
+	set := &RuleSet{suppressions: suppressions, version: version, sources: sources}
+	for _, rule := range rules {
+		switch {
+		case rule.Field != "":
+			set.fieldRules = append(set.fieldRules, FieldRule{Field: rule.Field, Rule: rule})
+		case rule.Pattern == "":
+			set.levelRules = append(set.levelRules, rule)
+		default:
+			set.rules = append(set.rules, rule)
+		}
+	}
+	return set
+}
+
+// RuleProvider supplies the active failure rules, such as a
+// detection.Engine that swaps in reloaded rules. Callers ask for the rules
+// every time they match, so a swap takes effect at once.
+type RuleProvider interface {
+	Rules() *RuleSet
+}
+
+// Version identifies the rule files the set was built from: it is a hash
+// of their names and contents, so processes reading the same files report
+// the same version.
+func (s *RuleSet) Version() string {
+	if s == nil {
+		return ""
+	}
+	return s.version
+}
+
+// Sources names the rule files added to the built-in rules, in load order.
+func (s *RuleSet) Sources() []string {
+	if s == nil {
+		return nil
+	}
+	return s.sources
+}
+
+// Rules returns the enabled rules matched against the log text, in match
+// order.
+func (s *RuleSet) Rules() []*Rule {
+	if s == nil {
+		return nil
+	}
+	return s.rules
+}
+
+// FieldRules returns the enabled rules matched against a field of structured
+// log lines, in match order.
+func (s *RuleSet) FieldRules() []FieldRule {
+	if s == nil {
+		return nil
+	}
+	return s.fieldRules
+}
+
+// LevelRules returns the enabled rules matching structured log lines on
+// their level alone, in match order.
+func (s *RuleSet) LevelRules() []*Rule {
+	if s == nil {
+		return nil
+	}
+	return s.levelRules
+}
+
+// All returns every enabled rule: the text rules, then the field rules,
+// then the level rules.
+func (s *RuleSet) All() []*Rule {
+	all := append([]*Rule(nil), s.Rules()...)
+	for _, fieldRule := range s.FieldRules() {
+		all = append(all, fieldRule.Rule)
+	}
+	return append(all, s.LevelRules()...)
+}
+
+// Suppressions returns the enabled suppressions.
+func (s *RuleSet) Suppressions() []*Suppression {
+	if s == nil {
+		return nil
+	}
+	return s.suppressions
+}
+
+// Match returns the first rule matching text and the text it matched, or nil.
+// Rules with a level are skipped, as text has none. A nil *RuleSet matches
+// nothing.
+func (s *RuleSet) Match(text string) (*Rule, string) {
+	return s.MatchLevel(text, LevelUnknown)
+}
+
+// MatchLevel is Match for the text of a structured line at level.
+func (s *RuleSet) MatchLevel(text string, level LogLevel) (*Rule, string) {
+	if s == nil {
+		return nil, ""
+	}
+	for _, rule := range s.rules {
+		if !rule.matchesLevel(level) {
+			continue
+		}
+		if match := rule.Match(text); match != "" {
+			return rule, match
+		}
+	}
+	return nil, ""
+}
+
+// DefaultRules returns the built-in rules shipped in rules/default.yaml.
+func DefaultRules() *RuleSet {
+	file, err := parseRuleFile(defaultRulesYAML)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in failure rules: %v", err))
+	}
+	return newRuleSet(file.Rules, file.Suppressions, RulesVersion(nil), nil)
+}
+
+// RuleSource is the content of one rule file, from a directory or a
+// ConfigMap key.
+type RuleSource struct {
+	Name string
+	Data []byte
+}
+
+// RulesVersion hashes the built-in rules and sources; it is the Version of
+// the RuleSet built from them.
+func RulesVersion(sources []RuleSource) string {
+	hash := sha256.New()
+	hash.Write(defaultRulesYAML)
+	for _, source := range sources {
+		fmt.Fprintf(hash, "\x00%s\x00", source.Name)
+		hash.Write(source.Data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// LoadRules returns the built-in rules combined with the rules in every
+// .yaml or .yml file in dir, read in file name order. Rules from dir are
+// tried before the built-in ones, except that a rule with the ID of a
+// built-in rule takes its place, or removes it when disabled. Suppressions
+// are merged the same way. An empty dir returns the built-in rules.
+func LoadRules(dir string) (*RuleSet, error) {
+	sources, err := ReadRuleDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return BuildRuleSet(sources)
+}
+
+// ReadRuleDir reads the rule files in dir in file name order.
+func ReadRuleDir(dir string) ([]RuleSource, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		// A mounted ConfigMap keeps its files in hidden directories and
+		// links them from dir; the links are followed by os.Stat
+		if strings.HasPrefix(entry.Name(), ".") || !IsRuleFileName(entry.Name()) {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, entry.Name())); err == nil && !info.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	sources := make([]RuleSource, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules file: %w", err)
+		}
+		sources = append(sources, RuleSource{Name: name, Data: data})
+	}
+	return sources, nil
+}
+
+// IsRuleFileName reports whether name is a rule file, a .yaml or .yml file.
+func IsRuleFileName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// BuildRuleSet validates sources, in order, and merges them with the
+// built-in rules. IDs must be unique across all sources.
+func BuildRuleSet(sources []RuleSource) (*RuleSet, error) {
+	defaults := DefaultRules()
+	if len(sources) == 0 {
+		return defaults, nil
+	}
+
+	var custom ruleFile
+	ruleIDs := make(map[string]bool)
+	suppressionIDs := make(map[string]bool)
+	names := make([]string, 0, len(sources))
+	for _, source := range sources {
+		file, err := parseRuleFile(source.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules file %s: %w", source.Name, err)
+		}
+		for _, rule := range file.Rules {
+			if ruleIDs[rule.ID] {
+				return nil, fmt.Errorf("invalid rules file %s: duplicate rule id %s", source.Name, rule.ID)
+			}
+			ruleIDs[rule.ID] = true
+		}
+		for _, suppression := range file.Suppressions {
+			if suppressionIDs[suppression.ID] {
+				return nil, fmt.Errorf("invalid rules file %s: duplicate suppression id %s", source.Name, suppression.ID)
+			}
+			suppressionIDs[suppression.ID] = true
+		}
+		custom.Rules = append(custom.Rules, file.Rules...)
+		custom.Suppressions = append(custom.Suppressions, file.Suppressions...)
+		names = append(names, source.Name)
+	}
+
+	return newRuleSet(
+		mergeByID(defaults.All(), custom.Rules,
+			func(r *Rule) string { return r.ID }, func(r *Rule) bool { return r.Disabled }),
+		mergeByID(defaults.suppressions, custom.Suppressions,
+			func(s *Suppression) string { return s.ID }, func(s *Suppression) bool { return s.Disabled }),
+		RulesVersion(sources), names), nil
+}
+
+// mergeByID puts the custom entries that are new before the built-in ones
+// and lets custom entries replace built-in entries with the same ID.
+// Disabled entries are dropped.
+func mergeByID[T any](builtin, custom []T, id func(T) string, disabled func(T) bool) []T {
+	overrides := make(map[string]T, len(custom))
+	for _, entry := range custom {
+		overrides[id(entry)] = entry
+	}
+	isBuiltin := make(map[string]bool, len(builtin))
+	for _, entry := range builtin {
+		isBuiltin[id(entry)] = true
+	}
+
+	var merged []T
+	for _, entry := range custom {
+		if !isBuiltin[id(entry)] && !disabled(entry) {
+			merged = append(merged, entry)
+		}
+	}
+	for _, entry := range builtin {
+		if override, ok := overrides[id(entry)]; ok {
+			entry = override
+		}
+		if !disabled(entry) {
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+// LoadRulesFromEnv loads the rules with the directory named by
+// FAILURE_RULES_DIR, typically a mounted ConfigMap, added to the built-in
+// ones.
+func LoadRulesFromEnv() (*RuleSet, error) {
+	return LoadRules(os.Getenv("FAILURE_RULES_DIR"))
+}
+
+func parseRuleFile(data []byte) (*ruleFile, error) {
+	var file ruleFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(file.Rules))
+	for _, rule := range file.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("empty rule")
+		}
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate rule id %s", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+	seen = make(map[string]bool, len(file.Suppressions))
+	for _, suppression := range file.Suppressions {
+		if suppression == nil {
+			return nil, fmt.Errorf("empty suppression")
+		}
+		if err := suppression.compile(); err != nil {
+			return nil, err
+		}
+		if seen[suppression.ID] {
+			return nil, fmt.Errorf("duplicate suppression id %s", suppression.ID)
+		}
+		seen[suppression.ID] = true
+	}
+	return &file, nil
+}
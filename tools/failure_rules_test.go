@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeByID(t *testing.T) {
+	type entry struct {
+		id       string
+		source   string
+		disabled bool
+	}
+	builtin := []entry{{"a", "builtin", false}, {"b", "builtin", false}, {"c", "builtin", false}}
+	tests := []struct {
+		name   string
+		custom []entry
+		want   []string // id/source
+	}{
+		{
+			name: "no custom entries",
+			want: []string{"a/builtin", "b/builtin", "c/builtin"},
+		},
+		{
+			name:   "new entries first",
+			custom: []entry{{"x", "custom", false}, {"y", "custom", false}},
+			want:   []string{"x/custom", "y/custom", "a/builtin", "b/builtin", "c/builtin"},
+		},
+		{
+			name:   "override keeps the built-in position",
+			custom: []entry{{"x", "custom", false}, {"b", "custom", false}},
+			want:   []string{"x/custom", "a/builtin", "b/custom", "c/builtin"},
+		},
+		{
+			name:   "disabled built-in",
+			custom: []entry{{"a", "custom", true}},
+			want:   []string{"b/builtin", "c/builtin"},
+		},
+		{
+			name:   "disabled new entry",
+			custom: []entry{{"x", "custom", true}},
+			want:   []string{"a/builtin", "b/builtin", "c/builtin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeByID(builtin, tt.custom,
+				func(e entry) string { return e.id }, func(e entry) bool { return e.disabled })
+			var got []string
+			for _, e := range merged {
+				got = append(got, e.id+"/"+e.source)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mergeByID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRuleSetOverrides(t *testing.T) {
+	rules, err := BuildRuleSet([]RuleSource{{Name: "custom.yaml", Data: []byte(`
+rules:
+  - id: oom-killed
+    pattern: 'out of memory'
+    category: resource
+    severity: low
+  - id: image-pull
+    disabled: true
+  - id: retry-budget
+    pattern: 'retry budget'
+    category: capacity
+    severity: high
+`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := rules.All()
+	if all[0].ID != "retry-budget" {
+		t.Errorf("first rule = %s, want the new rule retry-budget", all[0].ID)
+	}
+	var ids []string
+	for _, rule := range all {
+		ids = append(ids, rule.ID)
+		if rule.ID == "oom-killed" && rule.Severity != SeverityLow {
+			t.Errorf("oom-killed severity = %s, want the override's low", rule.Severity)
+		}
+	}
+	if slices.Contains(ids, "image-pull") {
+		t.Error("disabled rule image-pull is still active")
+	}
+	if !slices.Contains(ids, "oom-killed") || !slices.Contains(ids, "crash-loop") {
+		t.Errorf("rules = %v, want the overridden and untouched built-in rules", ids)
+	}
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxFailureExamples is how many distinct messages are kept as examples of
+// an aggregated failure.
+const maxFailureExamples = 3
+
+// signatureReplacements normalize the variable parts of a failure message.
+// They are applied in order, so timestamps and UUIDs are replaced before
+// their digits would be taken for plain numbers.
+var signatureReplacements = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<ts>"},
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`), "<ts>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:]*\](:\d+)?`), "<ip>"},
+	{regexp.MustCompile(`\b([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b`), "<ip>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`:\d{2,5}\b`), ":<port>"},
+	{regexp.MustCompile(`\b(\d+(\.\d+)?(ns|us|ms|s|m|h))+\b`), "<duration>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?\b`), "<n>"},
+}
+
+// FailureSignature normalizes the variable parts of a failure message (IPs,
+// ports, UUIDs, hex IDs, durations, numbers and timestamps) so repeated
+// occurrences of the same failure share one signature.
+func FailureSignature(message string) string {
+	signature := message
+	for _, r := range signatureReplacements {
+		signature = r.re.ReplaceAllString(signature, r.replacement)
+	}
+	return strings.Join(strings.Fields(signature), " ")
+}
@@ -0,0 +1,125 @@
+package tools
+
+import "testing"
+
+func TestFailureSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "iso timestamp",
+			message: "2024-05-01T12:30:45.123Z request failed",
+			want:    "<ts> request failed",
+		},
+		{
+			name:    "timestamp with offset",
+			message: "at 2024-05-01 12:30:45+02:00 request failed",
+			want:    "at <ts> request failed",
+		},
+		{
+			name:    "time of day",
+			message: "job started 08:15:00.5 and failed",
+			want:    "job started <ts> and failed",
+		},
+		{
+			name:    "uuid before hex and numbers",
+			message: "order 3f2b8c1e-9d4a-4e6b-8c2f-1a2b3c4d5e6f not found",
+			want:    "order <uuid> not found",
+		},
+		{
+			name:    "ipv4 with port",
+			message: "dial tcp 10.0.0.2:5432: connect: connection refused",
+			want:    "dial tcp <ip>: connect: connection refused",
+		},
+		{
+			name:    "bracketed ipv6 with port",
+			message: "dial tcp [fd00::1]:443: i/o timeout",
+			want:    "dial tcp <ip>: i/o timeout",
+		},
+		{
+			name:    "full ipv6",
+			message: "no route to 2001:0db8:85a3:0000:0000:8a2e:0370:7334",
+			want:    "no route to <ip>",
+		},
+		{
+			name:    "hex ids",
+			message: "panic at 0xc000120000 in trace 4bf92f3577b34da6",
+			want:    "panic at <hex> in trace <hex>",
+		},
+		{
+			name:    "port on a host name",
+			message: "cannot reach redis.cache.svc:6379",
+			want:    "cannot reach redis.cache.svc:<port>",
+		},
+		{
+			name:    "durations",
+			message: "request timed out after 1m30s, retrying in 250ms",
+			want:    "request timed out after <duration>, retrying in <duration>",
+		},
+		{
+			name:    "numbers",
+			message: "processed 42 of 1000 items, 0.5 ratio",
+			want:    "processed <n> of <n> items, <n> ratio",
+		},
+		{
+			name:    "words with digits",
+			message: "http2 stream closed by utf8 decoder",
+			want:    "http2 stream closed by utf8 decoder",
+		},
+		{
+			name:    "whitespace",
+			message: "  connection\treset   by peer  ",
+			want:    "connection reset by peer",
+		},
+		{
+			name:    "empty",
+			message: "",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailureSignature(tt.message); got != tt.want {
+				t.Errorf("FailureSignature(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureSignatureGroupsOccurrences(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "different addresses and durations",
+			a:    "2024-05-01T12:00:00Z ERROR connection refused to 10.0.0.2:5432 after 31s",
+			b:    "2024-05-01T12:05:10Z ERROR connection refused to 10.0.0.7:5432 after 2s",
+			same: true,
+		},
+		{
+			name: "different request ids",
+			a:    "request 6b1f0a2e-0c3d-4d5e-9f10-112233445566 failed with status 503",
+			b:    "request 0e9d8c7b-6a5f-4e3d-2c1b-a09f8e7d6c5b failed with status 500",
+			same: true,
+		},
+		{
+			name: "different failures",
+			a:    "connection refused to 10.0.0.2:5432",
+			b:    "connection reset by 10.0.0.2:5432",
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := FailureSignature(tt.a), FailureSignature(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("FailureSignature(%q) = %q, FailureSignature(%q) = %q, same = %v, want %v",
+					tt.a, a, tt.b, b, a == b, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// FailureFingerprint identifies a failure across pods, restarts and scans:
+// a hash of the workload's namespace, kind and name, the container, the rule
+// (or, for failures without one, the category) and the message signature
+// with the pod's name taken out. Replicas of a workload share fingerprints,
+// and the failure seen an hour later has the one seen now.
+func FailureFingerprint(namespace string, workload Workload, containerName, podName string, failure Failure) string {
+	classification := failure.RuleID
+	if classification == "" {
+		classification = failure.Category
+	}
+	message := failure.Message
+	if podName != "" {
+		message = strings.ReplaceAll(message, podName, "<pod>")
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		namespace, workload.Kind, workload.Name, containerName, classification, FailureSignature(message),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// FingerprintFailures sets the fingerprint of failures found in a pod's
+// container; containerName is empty for failures of the pod or workload as
+// a whole, such as Warning events.
+func FingerprintFailures(failures []Failure, namespace string, workload Workload, containerName, podName string) {
+	for i := range failures {
+		failures[i].Fingerprint = FailureFingerprint(namespace, workload, containerName, podName, failures[i])
+	}
+}
+
+// FingerprintSet returns the distinct fingerprints of failures, sorted, or
+// nil when one of them has no fingerprint.
+func FingerprintSet(failures []Failure) []string {
+	fingerprints := make([]string, 0, len(failures))
+	seen := make(map[string]bool)
+	for _, failure := range failures {
+		if failure.Fingerprint == "" {
+			return nil
+		}
+		if !seen[failure.Fingerprint] {
+			seen[failure.Fingerprint] = true
+			fingerprints = append(fingerprints, failure.Fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+	return fingerprints
+}
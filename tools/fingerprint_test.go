@@ -0,0 +1,75 @@
+package tools
+
+import "testing"
+
+func TestFailureFingerprint(t *testing.T) {
+	workload := Workload{Kind: "Deployment", Name: "api"}
+	base := FailureFingerprint("shop", workload, "app", "api-7d9f8b6c5d-x2k4p",
+		Failure{RuleID: "connection-refused", Message: "dial tcp 10.0.0.1:5432: connection refused", LineNumber: 12})
+	tests := []struct {
+		name      string
+		namespace string
+		workload  Workload
+		container string
+		pod       string
+		failure   Failure
+		same      bool
+	}{
+		{
+			name:      "other replica, line and address",
+			namespace: "shop",
+			workload:  workload,
+			container: "app",
+			pod:       "api-7d9f8b6c5d-q8w2m",
+			failure:   Failure{RuleID: "connection-refused", Message: "dial tcp 10.0.0.7:5432: connection refused", LineNumber: 480},
+			same:      true,
+		},
+		{
+			name:      "other rule",
+			namespace: "shop",
+			workload:  workload,
+			container: "app",
+			pod:       "api-7d9f8b6c5d-x2k4p",
+			failure:   Failure{RuleID: "timeout", Message: "dial tcp 10.0.0.1:5432: connection refused"},
+		},
+		{
+			name:      "other container",
+			namespace: "shop",
+			workload:  workload,
+			container: "sidecar",
+			pod:       "api-7d9f8b6c5d-x2k4p",
+			failure:   Failure{RuleID: "connection-refused", Message: "dial tcp 10.0.0.1:5432: connection refused"},
+		},
+		{
+			name:      "same name, other kind",
+			namespace: "shop",
+			workload:  Workload{Kind: "StatefulSet", Name: "api"},
+			container: "app",
+			pod:       "api-0",
+			failure:   Failure{RuleID: "connection-refused", Message: "dial tcp 10.0.0.1:5432: connection refused"},
+		},
+		{
+			name:      "other namespace",
+			namespace: "staging",
+			workload:  workload,
+			container: "app",
+			pod:       "api-7d9f8b6c5d-x2k4p",
+			failure:   Failure{RuleID: "connection-refused", Message: "dial tcp 10.0.0.1:5432: connection refused"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FailureFingerprint(tt.namespace, tt.workload, tt.container, tt.pod, tt.failure)
+			if (got == base) != tt.same {
+				t.Errorf("FailureFingerprint() = %s, base %s, want same = %v", got, base, tt.same)
+			}
+		})
+	}
+
+	// Each replica names itself in the message
+	first := FailureFingerprint("shop", workload, "app", "api-7d9f8b6c5d-x2k4p", Failure{Category: "leader-election", Message: "api-7d9f8b6c5d-x2k4p lost its lease"})
+	second := FailureFingerprint("shop", workload, "app", "api-7d9f8b6c5d-q8w2m", Failure{Category: "leader-election", Message: "api-7d9f8b6c5d-q8w2m lost its lease"})
+	if first != second {
+		t.Errorf("FailureFingerprint() = %s and %s for replicas naming themselves, want the same", first, second)
+	}
+}
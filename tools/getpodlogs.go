@@ -1,35 +1,70 @@
 package tools
 
 import (
+	"bufio"
 	"context"
 	"fmt"
-	"io"
-	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func GetPodLogsSince(client *kubernetes.Clientset, namespace, podName, containerName string, tailLines int64, sinceTime *metav1.Time) (string, error) {
+// GetPodLogsSince fetches the container's logs written after its cursor and
+// returns them with the time of the request. The cursor is left in place:
+// callers advance it to that time once the logs are processed, so logs whose
+// analysis failed are read again, and flush the store once per pass.
+func GetPodLogsSince(ctx context.Context, source LogSource, cursors CursorStore, namespace, podName, containerName string, tailLines int64, previous bool) (string, time.Time, error) {
+	key := CursorKey(namespace, podName, containerName)
+	since, err := cursors.Get(key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read log cursor for %s: %w", key, err)
+	}
+
+	// The cursor moves to when the request was made, so lines written while
+	// the response is read are seen again rather than skipped
+	fetchedAt := time.Now()
+	logs, err := source.GetLogs(ctx, LogRequest{
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		TailLines:     tailLines,
+		Previous:      previous,
+		SinceTime:     since,
+		Timestamps:    true,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return logs, fetchedAt, nil
+}
+
+// StreamPodLogs follows a container's log and calls onLine for every line as
+// it arrives, prefixed with its timestamp. It returns nil when the stream ends cleanly, which happens when
+// the container terminates.
+func StreamPodLogs(ctx context.Context, client kubernetes.Interface, namespace, podName, containerName string, sinceTime *metav1.Time, onLine func(line string)) error {
 	podLogOpts := &corev1.PodLogOptions{
-		Container: containerName,
-		TailLines: &tailLines,
-		SinceTime: sinceTime,
+		Container:  containerName,
+		Follow:     true,
+		SinceTime:  sinceTime,
+		Timestamps: true,
 	}
 
 	req := client.CoreV1().Pods(namespace).GetLogs(podName, podLogOpts)
-	podLogs, err := req.Stream(context.Background())
+	podLogs, err := req.Stream(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to stream logs for pod %s/%s container %s: %w", namespace, podName, containerName, err)
+		return fmt.Errorf("failed to follow logs for pod %s/%s container %s: %w", namespace, podName, containerName, err)
 	}
 	defer podLogs.Close()
 
-	buf := new(strings.Builder)
-	_, err = io.Copy(buf, podLogs)
-	if err != nil {
-		return "", fmt.Errorf("failed to read log stream: %w", err)
+	scanner := bufio.NewScanner(podLogs)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		onLine(scanner.Text())
 	}
-
-	return buf.String(), nil
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read log stream: %w", err)
+	}
+	return nil
 }
@@ -38,28 +38,53 @@ func (t *GitHubTool) Name() string {
 	return "github_issues"
 }
 
+// Execute searches the issues of input["repo"] for input["query"], or for
+// the issues linked to input["fingerprints"] ([]string) when it is set.
 func (t *GitHubTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	repo, _ := input["repo"].(string)
+	if repo == "" {
+		repo = "vasudevchavan/K8sLogmonitor"
+	}
+
+	if fingerprints, ok := input["fingerprints"].([]string); ok {
+		return t.SearchLinkedIssues(ctx, fingerprints, repo)
+	}
+
 	query, ok := input["query"].(string)
 	if !ok {
 		return nil, errors.New("query must be a string")
 	}
 
-	repo, _ := input["repo"].(string)
-	if repo == "" {
-		repo = "vasudevchavan/K8sLogmonitor"
-	}
+	return t.SearchIssues(ctx, query, repo)
+}
+
+// maxLinkedFingerprints is how many fingerprints one search looks for;
+// GitHub allows five OR operators per query.
+const maxLinkedFingerprints = 5
 
-	return t.SearchIssues(query, repo)
+// SearchLinkedIssues returns the issues that mention one of the failure
+// fingerprints, which is how an issue is linked to a failure. Unlike
+// SearchIssues it does not fall back to a broader search.
+func (t *GitHubTool) SearchLinkedIssues(ctx context.Context, fingerprints []string, repo string) ([]GitHubIssue, error) {
+	if len(fingerprints) == 0 {
+		return nil, nil
+	}
+	if len(fingerprints) > maxLinkedFingerprints {
+		fingerprints = fingerprints[:maxLinkedFingerprints]
+	}
+	searchQuery := fmt.Sprintf("repo:%s %s in:title,body", repo, strings.Join(fingerprints, " OR "))
+	apiURL := fmt.Sprintf("https://api.github.com/search/issues?q=%s&sort=updated&per_page=5", url.QueryEscape(searchQuery))
+	return t.doSearch(ctx, apiURL)
 }
 
-func (t *GitHubTool) SearchIssues(query, repo string) ([]GitHubIssue, error) {
+func (t *GitHubTool) SearchIssues(ctx context.Context, query, repo string) ([]GitHubIssue, error) {
 	// Search in both title and body content
 	searchQuery := fmt.Sprintf("repo:%s %s in:title,body", repo, query)
 	encodedQuery := url.QueryEscape(searchQuery)
 
 	apiURL := fmt.Sprintf("https://api.github.com/search/issues?q=%s&sort=updated&per_page=5", encodedQuery)
 	
-	issues, err := t.doSearch(apiURL)
+	issues, err := t.doSearch(ctx, apiURL)
 	if err != nil {
 		return nil, err
 	}
@@ -69,15 +94,15 @@ func (t *GitHubTool) SearchIssues(query, repo string) ([]GitHubIssue, error) {
 		broadQuery := fmt.Sprintf("repo:%s", repo)
 		encodedBroadQuery := url.QueryEscape(broadQuery)
 		broadURL := fmt.Sprintf("https://api.github.com/search/issues?q=%s&sort=updated&per_page=5", encodedBroadQuery)
-		return t.doSearch(broadURL)
+		return t.doSearch(ctx, broadURL)
 	}
 	
 	return issues, nil
 }
 
-func (t *GitHubTool) doSearch(url string) ([]GitHubIssue, error) {
+func (t *GitHubTool) doSearch(ctx context.Context, url string) ([]GitHubIssue, error) {
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -109,9 +134,21 @@ func (t *GitHubTool) FormatIssuesForLLM(issues []GitHubIssue) string {
 	if len(issues) == 0 {
 		return "No related GitHub issues found."
 	}
+	return t.formatIssues("Related GitHub Issues:", issues)
+}
+
+// FormatLinkedIssuesForLLM lists the issues filed for the failures
+// themselves, or returns "" when there are none.
+func (t *GitHubTool) FormatLinkedIssuesForLLM(issues []GitHubIssue) string {
+	if len(issues) == 0 {
+		return ""
+	}
+	return t.formatIssues("Linked GitHub Issues (filed for these failures):", issues)
+}
 
+func (t *GitHubTool) formatIssues(header string, issues []GitHubIssue) string {
 	var result strings.Builder
-	result.WriteString("Related GitHub Issues:\n")
+	result.WriteString(header + "\n")
 
 	for i, issue := range issues {
 		if i >= 3 { // Limit to top 3 issues
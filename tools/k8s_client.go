@@ -3,13 +3,10 @@ package tools
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strings"
-	"time"
 
-	corev1 "k8s.io/api/core/v1"
+	appconfig "github.com/vasudevchavan/K8sLogmonitor/config"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,6 +14,7 @@ import (
 )
 
 func NewK8sClient() (*kubernetes.Clientset, error) {
+	thresholds := appconfig.DefaultThresholds
 	var kubeconfig string
 	if kubeConfigPath := os.Getenv("KUBECONFIG"); kubeConfigPath != "" {
 		kubeconfig = kubeConfigPath
@@ -40,6 +38,8 @@ func NewK8sClient() (*kubernetes.Clientset, error) {
 			return nil, fmt.Errorf("failed to build in-cluster config: %w", err)
 		}
 	}
+	config.QPS = thresholds.ClientQPS
+	config.Burst = thresholds.ClientBurst
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
@@ -47,31 +47,19 @@ func NewK8sClient() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-// GetPodLogs fetches the last 'tailLines' of logs from a pod container
 func Int64Ptr(i int64) *int64 { return &i }
 
-func GetPodLogs(client *kubernetes.Clientset, namespace, podName, containerName string, tailLines int64) (string, error) {
-	podLogOpts := &corev1.PodLogOptions{
-		Container: containerName,
-		TailLines: Int64Ptr(tailLines),
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req := client.CoreV1().Pods(namespace).GetLogs(podName, podLogOpts)
-	podLogs, err := req.Stream(ctx)
-	if err != nil {
-		return "", fmt.Errorf("error streaming logs: %w", err)
-	}
-	defer podLogs.Close()
-
-	buf := new(strings.Builder)
-	if _, err := io.Copy(buf, podLogs); err != nil {
-		return "", fmt.Errorf("error reading logs: %w", err)
-	}
-
-	return buf.String(), nil
+// GetPodLogs returns the last tailLines lines of a container's logs. With
+// previous set it reads the logs of the container's last terminated instance,
+// which is where a crashlooping container's stack trace ends up.
+func GetPodLogs(ctx context.Context, client *kubernetes.Clientset, namespace, podName, containerName string, tailLines int64, previous bool) (string, error) {
+	return NewAPILogSource(client).GetLogs(ctx, LogRequest{
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		TailLines:     tailLines,
+		Previous:      previous,
+	})
 }
 
 func homeDir() string {
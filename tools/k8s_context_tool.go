@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -11,7 +12,7 @@ import (
 )
 
 type K8sContextTool struct {
-	client *kubernetes.Clientset
+	client kubernetes.Interface
 }
 
 type PodContext struct {
@@ -20,9 +21,11 @@ type PodContext struct {
 	Resources    map[string]interface{} `json:"resources"`
 	NodeInfo     string                 `json:"node_info"`
 	Dependencies []string               `json:"dependencies"`
+	// Terminations explains the failed exits of the pod's containers
+	Terminations []ExitInterpretation   `json:"terminations,omitempty"`
 }
 
-func NewK8sContextTool(client *kubernetes.Clientset) *K8sContextTool {
+func NewK8sContextTool(client kubernetes.Interface) *K8sContextTool {
 	return &K8sContextTool{client: client}
 }
 
@@ -51,6 +54,11 @@ func (t *K8sContextTool) Execute(ctx context.Context, input map[string]interface
 
 	var eventMsgs []string
 	for _, event := range events.Items {
+		// Field selectors are not applied by every client, e.g. the fake
+		// clientset used for replays
+		if event.InvolvedObject.Name != podName {
+			continue
+		}
 		eventMsgs = append(eventMsgs, fmt.Sprintf("%s: %s", event.Reason, event.Message))
 	}
 
@@ -65,7 +73,7 @@ func (t *K8sContextTool) Execute(ctx context.Context, input map[string]interface
 
 	// Get resource info
 	resources := make(map[string]interface{})
-	for _, container := range pod.Spec.Containers {
+	for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
 		if container.Resources.Requests != nil || container.Resources.Limits != nil {
 			resources[container.Name] = map[string]interface{}{
 				"requests": container.Resources.Requests,
@@ -80,6 +88,7 @@ func (t *K8sContextTool) Execute(ctx context.Context, input map[string]interface
 		Resources:    resources,
 		NodeInfo:     nodeInfo,
 		Dependencies: getDependencies(pod),
+		Terminations: InterpretPodTerminations(pod),
 	}
 
 	return podContext, nil
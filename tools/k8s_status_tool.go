@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type ContainerStatusTool struct {
+	client kubernetes.Interface
+}
+
+func NewContainerStatusTool(client kubernetes.Interface) *ContainerStatusTool {
+	return &ContainerStatusTool{client: client}
+}
+
+func (t *ContainerStatusTool) Name() string {
+	return "k8s_container_status"
+}
+
+// Execute returns a ContainerStatusResult for the requested container, which
+// may be a regular, init or ephemeral container, along with the failures the
+// pod status reports for it. A container without a status yet, e.g. in an
+// unschedulable pod, gets an empty status. Without a container_name only the
+// pod's workload is returned.
+func (t *ContainerStatusTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	namespace, _ := input["namespace"].(string)
+	podName, _ := input["pod_name"].(string)
+	containerName, _ := input["container_name"].(string)
+
+	if namespace == "" || podName == "" {
+		return nil, errors.New("namespace and pod_name required")
+	}
+
+	pod, err := t.client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+	if containerName == "" {
+		return ContainerStatusResult{Workload: PodWorkload(pod)}, nil
+	}
+
+	result, found := FindContainerStatus(pod, containerName)
+	if !found {
+		for _, ref := range PodContainers(pod) {
+			if ref.Name == containerName {
+				result = ContainerStatusResult{Kind: ref.Kind, Status: corev1.ContainerStatus{Name: containerName}}
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("container %s not found in pod %s/%s", containerName, namespace, podName)
+	}
+	result.Failures = DetectStatusFailures(pod, containerName)
+	result.Workload = PodWorkload(pod)
+	return result, nil
+}
@@ -3,17 +3,26 @@ package tools
 import (
 	"context"
 	"errors"
-	"regexp"
-
-	"k8s.io/client-go/kubernetes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 )
 
 type K8sTool struct {
-	client *kubernetes.Clientset
+	source  LogSource
+	cursors CursorStore
+
+	mu      sync.Mutex
+	pending map[string]time.Time // key: namespace/pod/container
 }
 
-func NewK8sTool(client *kubernetes.Clientset) *K8sTool {
-	return &K8sTool{client: client}
+// NewK8sTool creates the log tool reading from source, the live API server
+// or collected logs. With a cursor store, each fetch only returns lines
+// written since the container was last analyzed; pass nil to always read the
+// full tail.
+func NewK8sTool(source LogSource, cursors CursorStore) *K8sTool {
+	return &K8sTool{source: source, cursors: cursors, pending: make(map[string]time.Time)}
 }
 
 // ADK Tool interface methods
@@ -21,6 +30,9 @@ func (t *K8sTool) Name() string {
 	return "k8s_logs"
 }
 
+// Execute fetches the logs of input["container_name"]. With a cursor store,
+// the cursor stays in place until a call with input["commit"] set advances
+// it past the last fetch, once its logs have been analyzed.
 func (t *K8sTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	namespace, ok := input["namespace"].(string)
 	if !ok {
@@ -42,46 +54,280 @@ func (t *K8sTool) Execute(ctx context.Context, input map[string]interface{}) (in
 		tailLines = 100
 	}
 	
-	return GetPodLogs(t.client, namespace, podName, containerName, tailLines)
+	previous, _ := input["previous"].(bool)
+	
+	if commit, _ := input["commit"].(bool); commit {
+		return nil, t.commit(CursorKey(namespace, podName, containerName))
+	}
+	
+	if t.cursors == nil {
+		return t.source.GetLogs(ctx, LogRequest{
+			Namespace:     namespace,
+			PodName:       podName,
+			ContainerName: containerName,
+			TailLines:     tailLines,
+			Previous:      previous,
+			Timestamps:    true,
+		})
+	}
+	
+	logs, fetchedAt, err := GetPodLogsSince(ctx, t.source, t.cursors, namespace, podName, containerName, tailLines, previous)
+	if err != nil {
+		return nil, err
+	}
+	// Reading the previous instance's logs uses the same cursor but never
+	// moves it
+	if !previous {
+		t.mu.Lock()
+		t.pending[CursorKey(namespace, podName, containerName)] = fetchedAt
+		t.mu.Unlock()
+	}
+	return logs, nil
+}
+
+// commit advances the cursor of key to its last fetch. The store is flushed
+// by its owner once per pass.
+func (t *K8sTool) commit(key string) error {
+	if t.cursors == nil {
+		return nil
+	}
+	t.mu.Lock()
+	fetchedAt, ok := t.pending[key]
+	delete(t.pending, key)
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := t.cursors.Set(key, fetchedAt); err != nil {
+		return fmt.Errorf("failed to advance log cursor for %s: %w", key, err)
+	}
+	return nil
 }
 
 type FailureDetectionTool struct {
-	patterns []*regexp.Regexp
+	rules RuleProvider
+	rates *ErrorRateTracker
+
+	mu      sync.Mutex
+	formats map[string]LogFormat // key: namespace/pod/container
 }
 
-func NewFailureDetectionTool() *FailureDetectionTool {
-	patternStrings := []string{
-		"panic:", "error:", "failed to .*", "connection refused",
-		"pull image", "startup error", "waiting to start", "imagepullbackoff",
-		"crashloopbackoff", "oomkilled", "out of memory", "memory limit",
-		"cpu throttling", "disk pressure", "evicted", "pending",
-		"readiness probe failed", "liveness probe failed", "startup probe failed",
-		"mount.*failed", "volume.*error", "secret.*not found", "configmap.*not found",
-		"service unavailable", "timeout", "deadline exceeded", "context canceled",
-		"permission denied", "forbidden", "unauthorized", "tls.*error",
-		"dns.*error", "network.*unreachable", "no route to host",
-	}
-	patterns := make([]*regexp.Regexp, len(patternStrings))
-	for i, p := range patternStrings {
-		patterns[i] = regexp.MustCompile("(?i)" + p)
+// NewFailureDetectionTool creates the detection tool matching logs with the
+// active rules of rules, usually the detection engine. With a rate tracker, a container whose failures
+// spike above its baseline gets an additional error-rate failure; pass nil
+// for collected logs, which have no history.
+func NewFailureDetectionTool(rules RuleProvider, rates *ErrorRateTracker) *FailureDetectionTool {
+	return &FailureDetectionTool{
+		rules:   rules,
+		rates:   rates,
+		formats: make(map[string]LogFormat),
 	}
-	return &FailureDetectionTool{patterns: patterns}
 }
 
 func (t *FailureDetectionTool) Name() string {
 	return "failure_detection"
 }
 
+// Execute detects failures in input["logs"] and returns them as []Failure.
+// The logs of the container's previous instance, input["previous_logs"], are
+// matched on their own and their failures come first, marked Previous, with
+// line numbers within those logs. When input["container_key"] is set, the
+// log format detected for that container is remembered so later calls with
+// a handful of lines are parsed the same way.
 func (t *FailureDetectionTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	logs, ok := input["logs"].(string)
 	if !ok {
 		return nil, errors.New("logs must be a string")
 	}
+	previousLogs, _ := input["previous_logs"].(string)
 	
-	var failures []string
-	for _, re := range t.patterns {
-		matches := re.FindAllString(logs, -1)
-		failures = append(failures, matches...)
+	containerKey, _ := input["container_key"].(string)
+	detector := NewDetector(t.rules.Rules())
+	var occurrences, failures []Failure
+	if previousLogs != "" {
+		previous := detector.Occurrences(previousLogs, t.formatFor(containerKey, previousLogs))
+		for i := range previous {
+			previous[i].Previous = true
+		}
+		occurrences = previous
+		failures = AggregateFailures(previous)
+	}
+	if logs != "" {
+		current := detector.Occurrences(logs, t.formatFor(containerKey, logs))
+		occurrences = append(occurrences, current...)
+		failures = append(failures, AggregateFailures(current)...)
+	}
+	if t.rates == nil || containerKey == "" {
+		return failures, nil
+	}
+	
+	rate := t.rates.Observe(containerKey, occurrences, time.Now())
+	if spike, ok := t.rates.Spike(rate); ok {
+		failures = append([]Failure{spike}, failures...)
 	}
 	return failures, nil
+}
+
+func (t *FailureDetectionTool) formatFor(containerKey, logs string) LogFormat {
+	if containerKey == "" {
+		return DetectFormat(strings.Split(logs, "\n"))
+	}
+	
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if format, known := t.formats[containerKey]; known {
+		return format
+	}
+	lines := strings.Split(logs, "\n")
+	format := DetectFormat(lines)
+	// Too few lines to be sure; detect again next time
+	if len(lines) >= formatSampleSize || format != LogFormatText {
+		t.formats[containerKey] = format
+	}
+	return format
+}
+
+// DefaultContextLines is how many lines around a failure the detection tool
+// keeps by default.
+const DefaultContextLines = 3
+
+// FieldRule matches a rule against one field of structured log lines; it
+// is compiled from a rule with a field key.
+type FieldRule struct {
+	Field string
+	Rule  *Rule
+}
+
+// Detector finds failures in container logs. Text lines are matched against
+// Rules; structured (JSON, logfmt, klog) lines are matched on their message
+// and error fields against Rules, on other fields against FieldRules, and on
+// their level alone against LevelRules.
+type Detector struct {
+	Rules      *RuleSet
+	FieldRules []FieldRule
+	LevelRules []*Rule
+	// ContextLines is how many lines before and after a failure are kept
+	// as its context
+	ContextLines int
+}
+
+// NewDetector returns a detector over rules, with their field and level
+// rules and the default context.
+func NewDetector(rules *RuleSet) *Detector {
+	return &Detector{Rules: rules, FieldRules: rules.FieldRules(), LevelRules: rules.LevelRules(), ContextLines: DefaultContextLines}
+}
+
+// Detect assembles logs into multiline events and reports at most one
+// failure per event, so the frames of a stack trace do not each produce their
+// own hit. A text event yields the full line the rule matched and a structured
+// event its parsed summary; the surrounding lines, including a whole stack
+// trace, are attached as context. Identical failures are merged with their
+// first and last occurrence, taken from the log timestamps when logs carry
+// them.
+func (d *Detector) Detect(logs string, format LogFormat) []Failure {
+	return AggregateFailures(d.Occurrences(logs, format))
+}
+
+// Occurrences returns one failure per matching event, in log order, before
+// identical failures are merged.
+func (d *Detector) Occurrences(logs string, format LogFormat) []Failure {
+	lines := strings.Split(logs, "\n")
+	var occurrences []Failure
+	for _, event := range AssembleEvents(logs) {
+		failure, ok := d.match(event, format)
+		if !ok {
+			continue
+		}
+		failure.Context = contextWindow(lines, event, d.ContextLines)
+		failure.Exception = ExtractException(event.Lines)
+		failure.RulesVersion = d.Rules.Version()
+		occurrences = append(occurrences, failure)
+	}
+	return occurrences
+}
+
+// match returns the failure an event reports, if any.
+func (d *Detector) match(event LogEvent, format LogFormat) (Failure, bool) {
+	if format != LogFormatText {
+		if parsed, ok := ParseLine(format, event.Lines[0]); ok {
+			rule := d.matchStructured(parsed, event)
+			if rule == nil {
+				return Failure{}, false
+			}
+			at := event.Timestamp
+			if at.IsZero() {
+				at = parsed.Timestamp
+			}
+			failure := newFailure(parsed.Summary(), event.Lines[0], event.StartLine, at, rule)
+			failure.Level, failure.Caller, failure.Fields = parsed.Level, parsed.Caller, parsed.Fields
+			return failure, true
+		}
+	}
+	
+	rule, _ := d.Rules.Match(event.Text())
+	if rule == nil {
+		return Failure{}, false
+	}
+	// Report the line the rule matched, such as the exception line of a
+	// trace, rather than the first line of the event
+	offset := 0
+	for i, line := range event.Lines {
+		if rule.Match(line) != "" {
+			offset = i
+			break
+		}
+	}
+	line := event.Lines[offset]
+	return newFailure(strings.TrimSpace(line), line, event.StartLine+offset, event.Timestamp, rule), true
+}
+
+// contextWindow returns the event's lines with n lines before and after it,
+// without timestamp prefixes.
+func contextWindow(lines []string, event LogEvent, n int) []string {
+	start := event.StartLine - 1 - n
+	if start < 0 {
+		start = 0
+	}
+	end := event.StartLine - 1 + len(event.Lines) + n
+	if end > len(lines) {
+		end = len(lines)
+	}
+	window := make([]string, 0, end-start)
+	for _, line := range lines[start:end] {
+		_, line = SplitLogTimestamp(strings.TrimRight(line, "\r"))
+		window = append(window, line)
+	}
+	// Trailing blank lines, such as the end of the logs, add nothing
+	for len(window) > 0 && strings.TrimSpace(window[len(window)-1]) == "" {
+		window = window[:len(window)-1]
+	}
+	return window
+}
+
+// matchStructured returns the rule a structured event is a failure of, or
+// nil. Pattern rules are tried first so that an error-level line gets the
+// category and severity of what it reports rather than of its level.
+func (d *Detector) matchStructured(parsed ParsedLine, event LogEvent) *Rule {
+	search := parsed.SearchText()
+	if len(event.Lines) > 1 {
+		search += "\n" + strings.Join(event.Lines[1:], "\n")
+	}
+	if rule, _ := d.Rules.MatchLevel(search, parsed.Level); rule != nil {
+		return rule
+	}
+	for _, fieldRule := range d.FieldRules {
+		if !fieldRule.Rule.matchesLevel(parsed.Level) {
+			continue
+		}
+		if value, ok := parsed.Fields[fieldRule.Field]; ok && fieldRule.Rule.Match(value) != "" {
+			return fieldRule.Rule
+		}
+	}
+	
+	for _, rule := range d.LevelRules {
+		if rule.matchesLevel(parsed.Level) {
+			return rule
+		}
+	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestDetectorStructured(t *testing.T) {
+	rules, err := BuildRuleSet([]RuleSource{{Name: "custom.yaml", Data: []byte(`
+rules:
+  - id: retry-budget
+    pattern: 'retry budget'
+    level: warn
+    category: capacity
+    severity: high
+  - id: server-error-status
+    field: status
+    pattern: '^5\d\d$'
+    category: http
+    severity: medium
+`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		format LogFormat
+		line   string
+		want   string // rule ID, "" for no failure
+		level  LogLevel
+		caller string
+	}{
+		{
+			name:   "error level without a matching pattern",
+			format: LogFormatJSON,
+			line:   `{"level":"error","caller":"orders.go:12","msg":"order rejected","order":"42"}`,
+			want:   "error-level",
+			level:  LevelError,
+			caller: "orders.go:12",
+		},
+		{
+			name:   "fatal level",
+			format: LogFormatLogfmt,
+			line:   `level=fatal msg="cannot open store" caller=main.go:30`,
+			want:   "fatal-level",
+			level:  LevelFatal,
+			caller: "main.go:30",
+		},
+		{
+			name:   "pattern before level",
+			format: LogFormatJSON,
+			line:   `{"level":"error","msg":"dial tcp 10.0.0.1:5432: connection refused"}`,
+			want:   "connection-refused",
+			level:  LevelError,
+		},
+		{
+			name:   "warn level without a matching pattern",
+			format: LogFormatJSON,
+			line:   `{"level":"warn","msg":"slow query"}`,
+		},
+		{
+			name:   "rule with a level at that level",
+			format: LogFormatJSON,
+			line:   `{"level":"warn","msg":"retry budget exhausted"}`,
+			want:   "retry-budget",
+			level:  LevelWarn,
+		},
+		{
+			name:   "rule with a level below that level",
+			format: LogFormatJSON,
+			line:   `{"level":"info","msg":"retry budget exhausted"}`,
+		},
+		{
+			name:   "rule with a level on text",
+			format: LogFormatText,
+			line:   `WARN retry budget exhausted`,
+		},
+		{
+			name:   "field rule",
+			format: LogFormatJSON,
+			line:   `{"level":"info","msg":"request served","status":"503"}`,
+			want:   "server-error-status",
+			level:  LevelInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := NewDetector(rules).Detect(tt.line, tt.format)
+			if tt.want == "" {
+				if len(failures) != 0 {
+					t.Fatalf("Detect() = %v, want no failures", FailureMessages(failures))
+				}
+				return
+			}
+			if len(failures) != 1 {
+				t.Fatalf("Detect() = %v, want 1 failure", FailureMessages(failures))
+			}
+			failure := failures[0]
+			if failure.RuleID != tt.want || failure.Category == "" {
+				t.Errorf("Detect() rule = %q, category %q, want rule %q with a category", failure.RuleID, failure.Category, tt.want)
+			}
+			if failure.Level != tt.level || failure.Caller != tt.caller {
+				t.Errorf("Detect() level = %s, caller %q, want %s, %q", failure.Level, failure.Caller, tt.level, tt.caller)
+			}
+			if tt.format != LogFormatText {
+				parsed, _ := ParseLine(tt.format, tt.line)
+				if !maps.Equal(failure.Fields, parsed.Fields) {
+					t.Errorf("Detect() fields = %v, want %v", failure.Fields, parsed.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestRuleLevelValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		wantErr string
+	}{
+		{
+			name: "level without a pattern",
+			rule: "{id: warn-level, level: warn, category: application}",
+		},
+		{
+			name:    "unknown level",
+			rule:    "{id: loud, level: loud, category: application}",
+			wantErr: `unknown level "loud"`,
+		},
+		{
+			name:    "field without a pattern",
+			rule:    "{id: status, field: status, level: error, category: http}",
+			wantErr: "pattern is required",
+		},
+		{
+			name:    "neither pattern nor level",
+			rule:    "{id: empty, category: application}",
+			wantErr: "pattern is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildRuleSet([]RuleSource{{Name: "custom.yaml", Data: []byte("rules:\n  - " + tt.rule + "\n")}})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("BuildRuleSet() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("BuildRuleSet() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFailureDetectionToolPreviousLogs(t *testing.T) {
+	tests := []struct {
+		name         string
+		previousLogs string
+		logs         string
+		want         []Failure // message, line number and previous flag
+	}{
+		{
+			name:         "both instances",
+			previousLogs: "INFO starting\nINFO ready\npanic: disk full",
+			logs:         "INFO starting\nERROR: lock file held",
+			want: []Failure{
+				{Message: "panic: disk full", LineNumber: 3, Previous: true},
+				{Message: "ERROR: lock file held", LineNumber: 2},
+			},
+		},
+		{
+			name:         "previous instance only",
+			previousLogs: "ERROR: disk full",
+			want:         []Failure{{Message: "ERROR: disk full", LineNumber: 1, Previous: true}},
+		},
+		{
+			name: "current instance only",
+			logs: "INFO starting\n\nERROR: disk full",
+			want: []Failure{{Message: "ERROR: disk full", LineNumber: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewFailureDetectionTool(staticRules{DefaultRules()}, nil).Execute(context.Background(), map[string]interface{}{
+				"logs":          tt.logs,
+				"previous_logs": tt.previousLogs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			failures := result.([]Failure)
+			if len(failures) != len(tt.want) {
+				t.Fatalf("Execute() = %v, want %d failures", FailureMessages(failures), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := failures[i]
+				if got.Message != want.Message || got.LineNumber != want.LineNumber || got.Previous != want.Previous {
+					t.Errorf("failure %d = %q at line %d, previous %v, want %q at line %d, previous %v",
+						i, got.Message, got.LineNumber, got.Previous, want.Message, want.LineNumber, want.Previous)
+				}
+			}
+		})
+	}
+}
+
+// staticRules provides a fixed rule set.
+type staticRules struct {
+	rules *RuleSet
+}
+
+func (r staticRules) Rules() *RuleSet {
+	return r.rules
+}
@@ -43,15 +43,15 @@ func NewLLMTool(apiKey string) *LLMTool {
 	}
 }
 
-func (t *LLMTool) GenerateRecommendation(context string) (string, error) {
-	if context == "" {
+func (t *LLMTool) GenerateRecommendation(ctx context.Context, podContext string) (string, error) {
+	if podContext == "" {
 		return "", errors.New("context cannot be empty")
 	}
 	if t.apiKey == "" {
 		return "No API key provided. Set LLM_API_KEY environment variable.", nil
 	}
 
-	prompt := fmt.Sprintf("Analyze these Kubernetes pod failures and provide specific troubleshooting recommendations. Include references to any GitHub issues mentioned:\n\n%s\n\nProvide actionable steps to resolve these issues. If GitHub issues are mentioned, reference them in your recommendations.", context)
+	prompt := fmt.Sprintf("Analyze these Kubernetes pod failures and provide specific troubleshooting recommendations. Include references to any GitHub issues mentioned:\n\n%s\n\nProvide actionable steps to resolve these issues. If GitHub issues are mentioned, reference them in your recommendations.", podContext)
 
 	reqBody := OpenAIRequest{
 		Model: "gpt-3.5-turbo",
@@ -67,7 +67,7 @@ func (t *LLMTool) GenerateRecommendation(context string) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -82,7 +82,7 @@ func (t *LLMTool) GenerateRecommendation(context string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 429 {
-		return t.getFallbackRecommendation(context), nil
+		return t.getFallbackRecommendation(podContext), nil
 	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("API request failed with status: %d", resp.StatusCode)
@@ -115,24 +115,41 @@ func (t *LLMTool) Execute(ctx context.Context, input map[string]interface{}) (in
 	if !ok {
 		return nil, errors.New("context must be a string")
 	}
-	return t.GenerateRecommendation(contextStr)
+	return t.GenerateRecommendation(ctx, contextStr)
 }
 
 func (t *LLMTool) getFallbackRecommendation(context string) string {
 	var recommendation string
+	lower := strings.ToLower(context)
 	
-	if strings.Contains(context, "pull image") {
+	if strings.Contains(context, "Init container failure:") {
+		recommendation = "Init Container Error - The pod's main containers stay in PodInitializing until this init container succeeds. Check: 1) Init container logs 2) Dependencies it waits for (database, migrations, services) 3) Its command and configuration"
+	} else if strings.Contains(lower, "pull image") || strings.Contains(lower, "[image_pull]") {
 		recommendation = "Image Pull Error - Check: 1) Image name/tag correctness 2) Registry accessibility 3) Image pull secrets 4) Network connectivity"
-	} else if strings.Contains(context, "oomkilled") {
+	} else if strings.Contains(lower, "[container_config]") {
+		recommendation = "Container Config Error - Check that the Secrets and ConfigMaps referenced by env, envFrom and volumes exist in the pod's namespace and contain the referenced keys"
+	} else if strings.Contains(lower, "oomkilled") || strings.Contains(lower, "[oom_killed]") {
 		recommendation = "OOM Error - Increase memory limits, check resource usage patterns, optimize application memory usage"
-	} else if strings.Contains(context, "crashloopbackoff") {
+	} else if strings.Contains(lower, "[unschedulable]") {
+		recommendation = "Scheduling Error - Check: 1) Node capacity against the pod's requests 2) Node selectors, affinity and taints/tolerations 3) PersistentVolumeClaim binding"
+	} else if strings.Contains(lower, "crashloopbackoff") || strings.Contains(lower, "[crash_loop]") || strings.Contains(lower, "[error_exit]") {
 		recommendation = "CrashLoop Error - Check application logs, verify startup commands, review health checks, fix configuration issues"
-	} else if strings.Contains(context, "probe failed") {
+	} else if strings.Contains(lower, "probe failed") {
 		recommendation = "Health Check Failed - Verify probe endpoints, adjust timeouts, check application startup time"
 	} else {
 		recommendation = "General troubleshooting: 1) Check pod events 2) Review logs 3) Verify resources 4) Check dependencies"
 	}
 	
+	// Explain how the containers exited, the hints the generic advice lacks
+	if _, exits, found := strings.Cut(context, exitInterpretationHeader+"\n"); found {
+		for _, line := range strings.Split(exits, "\n") {
+			if !strings.HasPrefix(line, "- ") {
+				break
+			}
+			recommendation += "\n\nContainer " + strings.TrimPrefix(line, "- ")
+		}
+	}
+	
 	// Add GitHub issues if present in context
 	if strings.Contains(context, "Related GitHub Issues:") {
 		lines := strings.Split(context, "\n")
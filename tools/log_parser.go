@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogFormat is the line format a container writes its logs in.
+type LogFormat string
+
+const (
+	LogFormatText   LogFormat = "text"
+	LogFormatJSON   LogFormat = "json"
+	LogFormatLogfmt LogFormat = "logfmt"
+	LogFormatKlog   LogFormat = "klog"
+)
+
+// LogLevel orders severities so rules can ask for "level >= error".
+type LogLevel int
+
+const (
+	LevelUnknown LogLevel = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	}
+	return "unknown"
+}
+
+// MarshalText renders the level by name, e.g. in JSON.
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a level name; see ParseLevel.
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	*l = ParseLevel(string(text))
+	return nil
+}
+
+// ParseLevel maps the level names used by common logging libraries, klog
+// severity letters and pino/bunyan numeric levels to a LogLevel.
+func ParseLevel(s string) LogLevel {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace", "debug", "dbg", "10", "20":
+		return LevelDebug
+	case "info", "information", "notice", "i", "30":
+		return LevelInfo
+	case "warn", "warning", "w", "40":
+		return LevelWarn
+	case "error", "err", "e", "50":
+		return LevelError
+	case "fatal", "panic", "critical", "crit", "emergency", "alert", "dpanic", "f", "60":
+		return LevelFatal
+	}
+	return LevelUnknown
+}
+
+// ParsedLine holds the fields extracted from a structured log line.
+type ParsedLine struct {
+	Format    LogFormat
+	Level     LogLevel
+	Message   string
+	Timestamp time.Time
+	Caller    string
+	Error     string
+	Fields    map[string]string
+}
+
+// formatSampleSize is how many non-empty lines are looked at to detect a
+// container's log format.
+const formatSampleSize = 20
+
+var (
+	klogLineRe   = regexp.MustCompile(`^([IWEF])(\d{4}) (\d{2}:\d{2}:\d{2}\.\d+)\s+\d+ ([^\]]+)\] (.*)$`)
+	logfmtPairRe = regexp.MustCompile(`([\w.\-]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+	klogMsgRe    = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)"\s*(.*)$`)
+
+	levelKeys   = []string{"level", "lvl", "severity", "log.level", "loglevel"}
+	messageKeys = []string{"msg", "message", "log", "event"}
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "t"}
+	callerKeys  = []string{"caller", "source", "logger", "func", "location"}
+	errorKeys   = []string{"error", "err", "exception", "error.message", "stacktrace", "stack"}
+)
+
+// DetectFormat picks the format most lines of a sample parse as. A format
+// only wins when it covers at least half of the sampled lines.
+func DetectFormat(lines []string) LogFormat {
+	counts := make(map[LogFormat]int)
+	sampled := 0
+	for _, line := range lines {
+		_, line = SplitLogTimestamp(line)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sampled++
+		for _, format := range []LogFormat{LogFormatJSON, LogFormatKlog, LogFormatLogfmt} {
+			if _, ok := ParseLine(format, line); ok {
+				counts[format]++
+				break
+			}
+		}
+		if sampled >= formatSampleSize {
+			break
+		}
+	}
+
+	best, bestCount := LogFormatText, 0
+	for _, format := range []LogFormat{LogFormatJSON, LogFormatKlog, LogFormatLogfmt} {
+		if counts[format] > bestCount {
+			best, bestCount = format, counts[format]
+		}
+	}
+	if sampled == 0 || bestCount*2 < sampled {
+		return LogFormatText
+	}
+	return best
+}
+
+// SplitLogTimestamp separates the RFC3339 timestamp the API server prefixes
+// lines with when logs are requested with timestamps. Lines without a prefix
+// are returned unchanged with a zero time.
+func SplitLogTimestamp(line string) (time.Time, string) {
+	if len(line) < len("2006-01-02T15:04:05Z") || line[0] < '0' || line[0] > '9' {
+		return time.Time{}, line
+	}
+	prefix, rest, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, line
+	}
+	t, err := time.Parse(time.RFC3339Nano, prefix)
+	if err != nil {
+		return time.Time{}, line
+	}
+	return t, rest
+}
+
+// ParseLine parses a line in the given format. It returns false when the
+// line is not in that format, e.g. a plain text banner in a JSON log.
+func ParseLine(format LogFormat, line string) (ParsedLine, bool) {
+	switch format {
+	case LogFormatJSON:
+		return parseJSONLine(line)
+	case LogFormatLogfmt:
+		return parseLogfmtLine(line)
+	case LogFormatKlog:
+		return parseKlogLine(line)
+	}
+	return ParsedLine{}, false
+}
+
+func parseJSONLine(line string) (ParsedLine, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return ParsedLine{}, false
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+		return ParsedLine{}, false
+	}
+
+	fields := make(map[string]string, len(raw))
+	flattenJSON("", raw, fields)
+	return structuredLine(LogFormatJSON, fields), true
+}
+
+func flattenJSON(prefix string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenJSON(key, nested, fields)
+		}
+	case string:
+		fields[prefix] = v
+	case nil:
+	default:
+		data, err := json.Marshal(v)
+		if err == nil {
+			fields[prefix] = string(data)
+		}
+	}
+}
+
+func parseLogfmtLine(line string) (ParsedLine, bool) {
+	pairs := logfmtPairRe.FindAllStringSubmatch(line, -1)
+	if len(pairs) < 2 {
+		return ParsedLine{}, false
+	}
+	fields := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		fields[pair[1]] = unquote(pair[2])
+	}
+	parsed := structuredLine(LogFormatLogfmt, fields)
+	if parsed.Level == LevelUnknown && parsed.Message == "" {
+		// key=value fragments inside free text are not logfmt
+		return ParsedLine{}, false
+	}
+	return parsed, true
+}
+
+func parseKlogLine(line string) (ParsedLine, bool) {
+	m := klogLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return ParsedLine{}, false
+	}
+
+	parsed := ParsedLine{
+		Format: LogFormatKlog,
+		Level:  ParseLevel(m[1]),
+		Caller: m[4],
+		Fields: make(map[string]string),
+	}
+	// klog omits the year
+	if ts, err := time.Parse("0102 15:04:05.999999", m[2]+" "+m[3]); err == nil {
+		parsed.Timestamp = ts.AddDate(time.Now().Year(), 0, 0)
+	}
+
+	// Structured klog (InfoS/ErrorS) writes a quoted message followed by
+	// key="value" pairs
+	message := m[5]
+	if sm := klogMsgRe.FindStringSubmatch(message); sm != nil {
+		message = unquote(`"` + sm[1] + `"`)
+		for _, pair := range logfmtPairRe.FindAllStringSubmatch(sm[2], -1) {
+			parsed.Fields[pair[1]] = unquote(pair[2])
+		}
+	}
+	parsed.Message = message
+	parsed.Error = firstField(parsed.Fields, errorKeys)
+	return parsed, true
+}
+
+func structuredLine(format LogFormat, fields map[string]string) ParsedLine {
+	parsed := ParsedLine{
+		Format:  format,
+		Level:   ParseLevel(firstField(fields, levelKeys)),
+		Message: firstField(fields, messageKeys),
+		Caller:  firstField(fields, callerKeys),
+		Error:   firstField(fields, errorKeys),
+		Fields:  fields,
+	}
+	if ts := firstField(fields, timeKeys); ts != "" {
+		parsed.Timestamp = parseLogTime(ts)
+	}
+	return parsed
+}
+
+func firstField(fields map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseLogTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	// Unix seconds or milliseconds as emitted by zap and pino
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		if f > 1e12 {
+			return time.UnixMilli(int64(f))
+		}
+		return time.Unix(0, int64(f*float64(time.Second)))
+	}
+	return time.Time{}
+}
+
+func unquote(value string) string {
+	if s, err := strconv.Unquote(value); err == nil {
+		return s
+	}
+	return strings.Trim(value, `"`)
+}
+
+// Summary renders the parsed line as a clean one-line failure description.
+func (p ParsedLine) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", p.Level, p.Message)
+	if p.Error != "" && p.Error != p.Message {
+		fmt.Fprintf(&b, " | error=%s", p.Error)
+	}
+	if p.Caller != "" {
+		fmt.Fprintf(&b, " | caller=%s", p.Caller)
+	}
+	return b.String()
+}
+
+// SearchText is what detection patterns match against for a structured line:
+// the message and error fields only, so words inside unrelated fields such as
+// URLs or request bodies do not produce hits.
+func (p ParsedLine) SearchText() string {
+	return strings.TrimSpace(p.Message + " " + p.Error)
+}
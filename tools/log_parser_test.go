@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		format LogFormat
+		line   string
+		ok     bool
+		want   ParsedLine
+	}{
+		{
+			name:   "json",
+			format: LogFormatJSON,
+			line:   `{"level":"error","ts":"2024-05-01T10:00:01Z","caller":"db/pool.go:42","msg":"db down","error":"dial tcp 10.0.0.1:5432: connection refused","attempt":3}`,
+			ok:     true,
+			want: ParsedLine{
+				Format:    LogFormatJSON,
+				Level:     LevelError,
+				Message:   "db down",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC),
+				Caller:    "db/pool.go:42",
+				Error:     "dial tcp 10.0.0.1:5432: connection refused",
+				Fields: map[string]string{
+					"level": "error", "ts": "2024-05-01T10:00:01Z", "caller": "db/pool.go:42", "msg": "db down",
+					"error": "dial tcp 10.0.0.1:5432: connection refused", "attempt": "3",
+				},
+			},
+		},
+		{
+			name:   "json with nested fields and a numeric level",
+			format: LogFormatJSON,
+			line:   `{"level":50,"time":1714557601000,"message":"payment failed","http":{"status":502},"user":null}`,
+			ok:     true,
+			want: ParsedLine{
+				Format:    LogFormatJSON,
+				Level:     LevelError,
+				Message:   "payment failed",
+				Timestamp: time.UnixMilli(1714557601000),
+				Fields:    map[string]string{"level": "50", "time": "1714557601000", "message": "payment failed", "http.status": "502"},
+			},
+		},
+		{
+			name:   "json of another shape",
+			format: LogFormatJSON,
+			line:   `[1, 2, 3]`,
+		},
+		{
+			name:   "invalid json",
+			format: LogFormatJSON,
+			line:   `{"level":"error"`,
+		},
+		{
+			name:   "logfmt",
+			format: LogFormatLogfmt,
+			line:   `time=2024-05-01T10:00:01Z level=warn msg="cache miss rate high" caller=cache.go:88 rate=0.4`,
+			ok:     true,
+			want: ParsedLine{
+				Format:    LogFormatLogfmt,
+				Level:     LevelWarn,
+				Message:   "cache miss rate high",
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC),
+				Caller:    "cache.go:88",
+				Fields: map[string]string{
+					"time": "2024-05-01T10:00:01Z", "level": "warn", "msg": "cache miss rate high", "caller": "cache.go:88", "rate": "0.4",
+				},
+			},
+		},
+		{
+			name:   "logfmt with escaped quotes",
+			format: LogFormatLogfmt,
+			line:   `lvl=err msg="bad \"order\" id" err="not found"`,
+			ok:     true,
+			want: ParsedLine{
+				Format:  LogFormatLogfmt,
+				Level:   LevelError,
+				Message: `bad "order" id`,
+				Error:   "not found",
+				Fields:  map[string]string{"lvl": "err", "msg": `bad "order" id`, "err": "not found"},
+			},
+		},
+		{
+			name:   "key=value fragments in free text",
+			format: LogFormatLogfmt,
+			line:   `retrying request id=42 attempt=3`,
+		},
+		{
+			name:   "klog",
+			format: LogFormatKlog,
+			line:   `E0501 10:00:01.123456       1 reflector.go:138] failed to list *v1.Pod: connection refused`,
+			ok:     true,
+			want: ParsedLine{
+				Format:    LogFormatKlog,
+				Level:     LevelError,
+				Message:   "failed to list *v1.Pod: connection refused",
+				Timestamp: time.Date(time.Now().Year(), 5, 1, 10, 0, 1, 123456000, time.UTC),
+				Caller:    "reflector.go:138",
+				Fields:    map[string]string{},
+			},
+		},
+		{
+			name:   "structured klog",
+			format: LogFormatKlog,
+			line:   `W0501 10:00:02.000001    7 controller.go:55] "Sync failed" pod="shop/api-0" err="timeout"`,
+			ok:     true,
+			want: ParsedLine{
+				Format:    LogFormatKlog,
+				Level:     LevelWarn,
+				Message:   "Sync failed",
+				Timestamp: time.Date(time.Now().Year(), 5, 1, 10, 0, 2, 1000, time.UTC),
+				Caller:    "controller.go:55",
+				Error:     "timeout",
+				Fields:    map[string]string{"pod": "shop/api-0", "err": "timeout"},
+			},
+		},
+		{
+			name:   "not klog",
+			format: LogFormatKlog,
+			line:   `ERROR something failed`,
+		},
+		{
+			name:   "text",
+			format: LogFormatText,
+			line:   `ERROR something failed`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLine(tt.format, tt.line)
+			if ok != tt.ok {
+				t.Fatalf("ParseLine() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got.Format != tt.want.Format || got.Level != tt.want.Level || got.Message != tt.want.Message ||
+				!got.Timestamp.Equal(tt.want.Timestamp) || got.Caller != tt.want.Caller || got.Error != tt.want.Error {
+				t.Errorf("ParseLine() = %+v, want %+v", got, tt.want)
+			}
+			if !maps.Equal(got.Fields, tt.want.Fields) {
+				t.Errorf("ParseLine() fields = %v, want %v", got.Fields, tt.want.Fields)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  LogFormat
+	}{
+		{
+			name:  "json with a banner",
+			lines: []string{"starting server v1.2", `{"level":"info","msg":"listening"}`, `{"level":"error","msg":"db down"}`},
+			want:  LogFormatJSON,
+		},
+		{
+			name:  "logfmt behind timestamps",
+			lines: []string{"2024-05-01T10:00:01Z level=info msg=started", "2024-05-01T10:00:02Z level=error msg=failed"},
+			want:  LogFormatLogfmt,
+		},
+		{
+			name:  "klog",
+			lines: []string{"I0501 10:00:01.000000       1 main.go:10] starting", "E0501 10:00:02.000000       1 main.go:20] failed"},
+			want:  LogFormatKlog,
+		},
+		{
+			name:  "mostly text",
+			lines: []string{"starting", "listening on :8080", `{"level":"info"}`},
+			want:  LogFormatText,
+		},
+		{
+			name: "empty",
+			want: LogFormatText,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.lines); got != tt.want {
+				t.Errorf("DetectFormat() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  LogLevel
+	}{
+		{"DEBUG", LevelDebug},
+		{"information", LevelInfo},
+		{"W", LevelWarn},
+		{"err", LevelError},
+		{"50", LevelError},
+		{"dpanic", LevelFatal},
+		{"verbose", LevelUnknown},
+	}
+	for _, tt := range tests {
+		if got := ParseLevel(tt.level); got != tt.want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", tt.level, got, tt.want)
+		}
+	}
+}
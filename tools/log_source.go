@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podListPageSize bounds how many pods a single list call returns.
+const podListPageSize = 500
+
+// logFetchTimeout bounds a single log request, so a stuck kubelet does not
+// hold an analysis worker.
+const logFetchTimeout = 10 * time.Second
+
+// LogRequest selects the logs of one container.
+type LogRequest struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	TailLines     int64 // 0 for every line
+	Previous      bool  // logs of the last terminated instance
+	SinceTime     time.Time
+	// Timestamps prefixes every line with its RFC3339 time, so failures can
+	// be dated
+	Timestamps bool
+}
+
+// SourceContainer is a container a LogSource has logs for.
+type SourceContainer struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	Kind          ContainerKind
+}
+
+// LogSource is where container logs are read from: the live API server or
+// logs collected earlier, such as a customer support bundle.
+type LogSource interface {
+	// ListContainers lists the containers matched by filter in namespace, or
+	// in every namespace when namespace is empty.
+	ListContainers(ctx context.Context, namespace string, filter *ScanFilter) ([]SourceContainer, error)
+	GetLogs(ctx context.Context, req LogRequest) (string, error)
+}
+
+// APILogSource reads logs from the Kubernetes API server.
+type APILogSource struct {
+	client kubernetes.Interface
+}
+
+func NewAPILogSource(client kubernetes.Interface) *APILogSource {
+	return &APILogSource{client: client}
+}
+
+// ListContainers lists pods in pages so a large cluster is not fetched in
+// one response.
+func (s *APILogSource) ListContainers(ctx context.Context, namespace string, filter *ScanFilter) ([]SourceContainer, error) {
+	var containers []SourceContainer
+	opts := filter.ListOptions()
+	opts.Limit = podListPageSize
+	for {
+		pods, err := s.client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			for _, container := range filter.Containers(&pod) {
+				containers = append(containers, SourceContainer{
+					Namespace:     pod.Namespace,
+					PodName:       pod.Name,
+					ContainerName: container.Name,
+					Kind:          container.Kind,
+				})
+			}
+		}
+		if pods.Continue == "" {
+			return containers, nil
+		}
+		opts.Continue = pods.Continue
+	}
+}
+
+// GetLogs reads the requested logs, giving up after logFetchTimeout.
+func (s *APILogSource) GetLogs(ctx context.Context, req LogRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, logFetchTimeout)
+	defer cancel()
+
+	podLogOpts := &corev1.PodLogOptions{
+		Container:  req.ContainerName,
+		Previous:   req.Previous,
+		Timestamps: req.Timestamps,
+	}
+	if req.TailLines > 0 {
+		podLogOpts.TailLines = Int64Ptr(req.TailLines)
+	}
+	if !req.SinceTime.IsZero() {
+		podLogOpts.SinceTime = &metav1.Time{Time: req.SinceTime}
+	}
+
+	podLogs, err := s.client.CoreV1().Pods(req.Namespace).GetLogs(req.PodName, podLogOpts).Stream(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to stream logs for pod %s/%s container %s: %w", req.Namespace, req.PodName, req.ContainerName, err)
+	}
+	defer podLogs.Close()
+
+	buf := new(strings.Builder)
+	if _, err := io.Copy(buf, podLogs); err != nil {
+		return "", fmt.Errorf("failed to read log stream: %w", err)
+	}
+	return buf.String(), nil
+}
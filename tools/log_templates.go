@@ -0,0 +1,451 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "github.com/vasudevchavan/K8sLogmonitor/config"
+)
+
+const (
+	// templateWildcard replaces the tokens in which the lines of a template
+	// differ.
+	templateWildcard = "<*>"
+	// templatePrefixDepth is how many leading tokens, after the token
+	// count, select the group of templates a line is compared with.
+	templatePrefixDepth = 2
+	// maxTemplatesPerWorkload bounds the memory of a workload whose lines
+	// never settle into templates.
+	maxTemplatesPerWorkload = 5000
+	// templateRateWeight is the weight of the latest window in a template's
+	// moving average of lines per window.
+	templateRateWeight = 0.2
+	// maxAnomaliesPerAnalysis keeps a deploy that changes many log lines
+	// from flooding one result.
+	maxAnomaliesPerAnalysis = 5
+)
+
+var (
+	novelTemplateRule = &Rule{
+		ID:          "novel-template",
+		Category:    "anomaly",
+		Severity:    SeverityMedium,
+		Description: "A log line unlike anything the workload logged while it was healthy.",
+		Remediation: "Check whether the new log line comes from a recent change and what it reports.",
+	}
+	templateSpikeRule = &Rule{
+		ID:          "template-spike",
+		Category:    "anomaly",
+		Severity:    SeverityMedium,
+		Description: "A log line the workload usually logs rarely is logged far more often.",
+		Remediation: "Check what drives the repeated log line, e.g. retries, a failing dependency or a traffic change.",
+	}
+)
+
+// LogTemplate is a log line pattern mined from a workload's logs, with the
+// variable parts replaced by <*> or by the placeholders of FailureSignature.
+type LogTemplate struct {
+	ID        int       `json:"id"`
+	Tokens    []string  `json:"tokens"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Baseline templates were learned while the workload was healthy
+	Baseline bool `json:"baseline"`
+	// Rate is the moving average of lines per window
+	Rate float64 `json:"rate"`
+	// WindowCount is the lines of the current window, not yet in Rate
+	WindowCount int64 `json:"window_count"`
+	// Spiked is set once the current window's spike has been reported
+	Spiked bool `json:"spiked,omitempty"`
+}
+
+func (t *LogTemplate) String() string {
+	return strings.Join(t.Tokens, " ")
+}
+
+// similarity is the share of positions in which tokens equal the template.
+func (t *LogTemplate) similarity(tokens []string) float64 {
+	equal := 0
+	for i, token := range tokens {
+		if t.Tokens[i] == token {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(tokens))
+}
+
+func (t *LogTemplate) merge(tokens []string) {
+	for i, token := range tokens {
+		if t.Tokens[i] != token {
+			t.Tokens[i] = templateWildcard
+		}
+	}
+}
+
+// TemplateMiner clusters one workload's log lines into templates online,
+// after Drain (He et al., "Drain: An Online Log Parsing Approach with Fixed
+// Depth Tree"): lines are grouped by token count and leading tokens, and a
+// line joins the most similar template of its group or starts a new one.
+type TemplateMiner struct {
+	// Groups is the fixed-depth tree flattened to its leaves, keyed by the
+	// token count and the leading tokens
+	Groups map[string][]*LogTemplate `json:"groups"`
+	NextID int                       `json:"next_id"`
+	// LearnStart starts the learning period at the workload's first healthy
+	// analysis; it is zero until then
+	LearnStart time.Time `json:"learn_start,omitempty"`
+	// WindowStart is where the current window of the templates' rates
+	// started
+	WindowStart time.Time `json:"window_start"`
+}
+
+func newTemplateMiner() *TemplateMiner {
+	return &TemplateMiner{Groups: make(map[string][]*LogTemplate), NextID: 1}
+}
+
+func templateTokens(line string) []string {
+	return strings.Fields(FailureSignature(line))
+}
+
+func templateGroupKey(tokens []string) string {
+	key := []string{fmt.Sprint(len(tokens))}
+	for i := 0; i < templatePrefixDepth && i < len(tokens); i++ {
+		// Placeholders vary between lines, so they cannot pick a group
+		if strings.HasPrefix(tokens[i], "<") {
+			key = append(key, templateWildcard)
+		} else {
+			key = append(key, tokens[i])
+		}
+	}
+	return strings.Join(key, " ")
+}
+
+// match returns the template tokens belong to, creating it when no template
+// is similar enough. created reports a new template.
+func (m *TemplateMiner) match(tokens []string, similarity float64, now time.Time) (template *LogTemplate, created bool) {
+	key := templateGroupKey(tokens)
+	best, bestScore := (*LogTemplate)(nil), similarity
+	for _, candidate := range m.Groups[key] {
+		if score := candidate.similarity(tokens); score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best != nil {
+		best.merge(tokens)
+		return best, false
+	}
+	if m.size() >= maxTemplatesPerWorkload {
+		return nil, false
+	}
+
+	template = &LogTemplate{ID: m.NextID, Tokens: append([]string(nil), tokens...), FirstSeen: now}
+	m.NextID++
+	m.Groups[key] = append(m.Groups[key], template)
+	return template, true
+}
+
+// advance folds the windows that ended by now into the templates' rates.
+// Every window counts once, with no lines when the workload was not
+// analyzed, however often or rarely analyses run.
+func (m *TemplateMiner) advance(window time.Duration, now time.Time) {
+	if m.WindowStart.IsZero() {
+		m.WindowStart = now
+		return
+	}
+	ended := now.Sub(m.WindowStart) / window
+	if ended <= 0 {
+		return
+	}
+	// The windows after the first one that ended had no lines
+	decay := math.Pow(1-templateRateWeight, float64(ended-1))
+	for _, group := range m.Groups {
+		for _, template := range group {
+			template.Rate = (templateRateWeight*float64(template.WindowCount) + (1-templateRateWeight)*template.Rate) * decay
+			template.WindowCount = 0
+			template.Spiked = false
+		}
+	}
+	m.WindowStart = m.WindowStart.Add(ended * window)
+}
+
+func (m *TemplateMiner) size() int {
+	n := 0
+	for _, group := range m.Groups {
+		n += len(group)
+	}
+	return n
+}
+
+// Templates returns the templates ordered by ID.
+func (m *TemplateMiner) Templates() []LogTemplate {
+	var templates []LogTemplate
+	for _, group := range m.Groups {
+		for _, template := range group {
+			templates = append(templates, *template)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return templates
+}
+
+// TemplateStore keeps a TemplateMiner per workload and flags anomalies: log
+// templates never seen while the workload was healthy and templates whose
+// frequency spikes. Miners are saved as JSON files in dir, when set, so a
+// restarted monitor keeps its baseline.
+type TemplateStore struct {
+	dir           string
+	learnPeriod   time.Duration
+	window        time.Duration
+	similarity    float64
+	spikeFactor   float64
+	minSpikeCount int
+
+	mu     sync.Mutex
+	miners map[string]*TemplateMiner // key: namespace/kind/workload
+}
+
+// NewTemplateStore creates a store persisting to dir, or keeping templates
+// in memory when dir is empty. Templates seen within learnPeriod of a
+// workload's first healthy analysis form its baseline; lines join a template
+// when at least similarity of their tokens match; a template spikes when a
+// window has at least minSpikeCount of its lines and spikeFactor times its
+// average per window.
+func NewTemplateStore(dir string, learnPeriod, window time.Duration, similarity, spikeFactor float64, minSpikeCount int) *TemplateStore {
+	return &TemplateStore{
+		dir:           dir,
+		learnPeriod:   learnPeriod,
+		window:        window,
+		similarity:    similarity,
+		spikeFactor:   spikeFactor,
+		minSpikeCount: minSpikeCount,
+		miners:        make(map[string]*TemplateMiner),
+	}
+}
+
+// NewTemplateStoreFromEnv persists templates in ANOMALY_STATE_DIR, or keeps
+// them in memory when it is unset, with the default thresholds.
+func NewTemplateStoreFromEnv() *TemplateStore {
+	return newDefaultTemplateStore(os.Getenv("ANOMALY_STATE_DIR"))
+}
+
+// NewMemoryTemplateStore keeps templates in memory with the default
+// thresholds, for one-off analyses of collected logs that must not feed the
+// baselines learned from the live cluster.
+func NewMemoryTemplateStore() *TemplateStore {
+	return newDefaultTemplateStore("")
+}
+
+func newDefaultTemplateStore(dir string) *TemplateStore {
+	thresholds := appconfig.DefaultThresholds
+	return NewTemplateStore(dir,
+		time.Duration(thresholds.AnomalyLearnMs)*time.Millisecond,
+		time.Duration(thresholds.AnomalyWindowMs)*time.Millisecond,
+		thresholds.AnomalySimilarity, thresholds.AnomalySpikeFactor, thresholds.AnomalyMinSpikeCount)
+}
+
+// templateStoreKey keys a workload's miner by kind too, so a Deployment and a
+// StatefulSet of the same name learn apart.
+func templateStoreKey(namespace string, workload Workload) string {
+	return namespace + "/" + workload.Kind + "/" + workload.Name
+}
+
+func parseTemplateStoreKey(key string) (string, Workload) {
+	namespace, rest, _ := strings.Cut(key, "/")
+	kind, name, _ := strings.Cut(rest, "/")
+	return namespace, Workload{Kind: kind, Name: name}
+}
+
+// miner returns the workload's miner, loading it from dir on first use. The
+// caller must hold mu.
+func (s *TemplateStore) miner(key string) (*TemplateMiner, error) {
+	if miner, ok := s.miners[key]; ok {
+		return miner, nil
+	}
+	miner := newTemplateMiner()
+	if s.dir != "" {
+		data, err := os.ReadFile(s.minerPath(key))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read log templates: %w", err)
+		default:
+			if err := json.Unmarshal(data, miner); err != nil {
+				return nil, fmt.Errorf("failed to parse log templates for %s: %w", key, err)
+			}
+		}
+	}
+	s.miners[key] = miner
+	return miner, nil
+}
+
+// minerPath names a workload's file; '_' never appears in namespace, kind
+// or workload names.
+func (s *TemplateStore) minerPath(key string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(key, "/", "_")+".json")
+}
+
+func (s *TemplateStore) save(key string, miner *TemplateMiner) error {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(miner)
+	if err != nil {
+		return fmt.Errorf("failed to encode log templates: %w", err)
+	}
+	if err := writeFileAtomic(s.minerPath(key), data); err != nil {
+		return fmt.Errorf("failed to write log templates: %w", err)
+	}
+	return nil
+}
+
+// Observe mines the templates of logs for the workload and returns the
+// anomalies found. During the learning period only healthy logs, those of
+// an analysis without other failures, are learned, so a crash does not
+// become part of the baseline. The period starts with the first healthy
+// logs: a workload first analyzed because it failed, as in watch mode, does
+// not run out of it before it learned anything. Lines count towards their
+// template's rate in the window they are observed in.
+func (s *TemplateStore) Observe(namespace string, workload Workload, logs string, healthy bool, now time.Time) ([]Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := templateStoreKey(namespace, workload)
+	miner, err := s.miner(key)
+	if err != nil {
+		return nil, err
+	}
+	if healthy && miner.LearnStart.IsZero() {
+		miner.LearnStart = now
+	}
+	learning := miner.LearnStart.IsZero() || now.Sub(miner.LearnStart) < s.learnPeriod
+	if learning && !healthy {
+		return nil, nil
+	}
+	miner.advance(s.window, now)
+
+	var anomalies failureAggregator
+	for _, event := range AssembleEvents(logs) {
+		// A stack trace is one event; its first line stands for it
+		tokens := templateTokens(event.Lines[0])
+		if len(tokens) == 0 {
+			continue
+		}
+		template, created := miner.match(tokens, s.similarity, now)
+		if template == nil {
+			continue
+		}
+		template.Count++
+		template.WindowCount++
+		template.LastSeen = now
+		if created {
+			template.Baseline = learning
+			if !learning && len(anomalies.failures) < maxAnomaliesPerAnalysis {
+				line := event.Lines[0]
+				anomalies.add(newFailure("new log template: "+template.String(), line, event.StartLine, event.Timestamp, novelTemplateRule))
+			}
+		}
+	}
+
+	for _, group := range miner.Groups {
+		for _, template := range group {
+			// A spike is reported once per window
+			if learning || !template.Baseline || template.Spiked || template.WindowCount < int64(s.minSpikeCount) ||
+				float64(template.WindowCount) <= s.spikeFactor*template.Rate || len(anomalies.failures) >= maxAnomaliesPerAnalysis {
+				continue
+			}
+			message := fmt.Sprintf("log template spiked to %d lines per %s (usually %.1f): %s",
+				template.WindowCount, s.window, template.Rate, template)
+			anomalies.add(newFailure(message, "", 0, now, templateSpikeRule))
+			template.Spiked = true
+		}
+	}
+
+	if err := s.save(key, miner); err != nil {
+		return anomalies.failures, err
+	}
+	return anomalies.failures, nil
+}
+
+// TemplateWorkload summarizes the templates learned for a workload.
+type TemplateWorkload struct {
+	Namespace string   `json:"namespace"`
+	Workload  Workload `json:"workload"`
+	Templates int      `json:"templates"`
+	Learning  bool     `json:"learning"`
+	// LearnStart is the workload's first healthy analysis, if any
+	LearnStart time.Time `json:"learn_start,omitempty"`
+}
+
+// Workloads lists the workloads with learned templates, including those
+// only persisted in dir.
+func (s *TemplateStore) Workloads() ([]TemplateWorkload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read log templates: %w", err)
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || strings.Count(name, "_") != 2 {
+				continue
+			}
+			if _, err := s.miner(strings.ReplaceAll(name, "_", "/")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	workloads := make([]TemplateWorkload, 0, len(s.miners))
+	for key, miner := range s.miners {
+		namespace, workload := parseTemplateStoreKey(key)
+		workloads = append(workloads, TemplateWorkload{
+			Namespace:  namespace,
+			Workload:   workload,
+			Templates:  miner.size(),
+			Learning:   miner.LearnStart.IsZero() || now.Sub(miner.LearnStart) < s.learnPeriod,
+			LearnStart: miner.LearnStart,
+		})
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Workload.String() < workloads[j].Workload.String()
+	})
+	return workloads, nil
+}
+
+// Templates returns the templates learned for a workload, or nil when it has
+// none.
+func (s *TemplateStore) Templates(namespace string, workload Workload) ([]LogTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := templateStoreKey(namespace, workload)
+	if _, loaded := s.miners[key]; !loaded {
+		if s.dir == "" {
+			return nil, nil
+		}
+		if _, err := os.Stat(s.minerPath(key)); err != nil {
+			return nil, nil
+		}
+	}
+	miner, err := s.miner(key)
+	if err != nil {
+		return nil, err
+	}
+	return miner.Templates(), nil
+}
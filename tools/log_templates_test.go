@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repeatLines returns n lines of format, each with its index.
+func repeatLines(format string, n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(format, i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTemplateStoreObserve(t *testing.T) {
+	type step struct {
+		at      time.Duration
+		healthy bool
+		logs    string
+		want    []string // rule IDs of the anomalies
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "no anomalies while learning",
+			steps: []step{
+				{0, true, "INFO serving request 1\nINFO serving request 2", nil},
+				{30 * time.Minute, true, "WARN cache miss for key 7", nil},
+				{30 * time.Minute, false, "worker pool exhausted, dropping job 3", nil},
+			},
+		},
+		{
+			name: "novel template after learning",
+			steps: []step{
+				{0, true, "INFO serving request 1", nil},
+				{2 * time.Hour, true, "INFO serving request 2\nworker pool exhausted, dropping job 3\nworker pool exhausted, dropping job 4",
+					[]string{"novel-template"}},
+				{3 * time.Hour, true, "worker pool exhausted, dropping job 5", nil},
+			},
+		},
+		{
+			name: "failing analyses do not start learning",
+			steps: []step{
+				{0, false, "ERROR connection refused", nil},
+				{2 * time.Hour, false, "ERROR connection refused", nil},
+				{2 * time.Hour, true, "INFO serving request 1", nil},
+				// Still learning, so failing logs are not learned
+				{2*time.Hour + 30*time.Minute, false, "INFO serving request 2\nworker pool exhausted, dropping job 3", nil},
+				{4 * time.Hour, false, "INFO serving request 3\nworker pool exhausted, dropping job 4", []string{"novel-template"}},
+			},
+		},
+		{
+			name: "spike of a baseline template",
+			steps: []step{
+				{0, true, "INFO serving request 1\nWARN retrying job 1", nil},
+				{2 * time.Hour, false, repeatLines("WARN retrying job %d", 30), []string{"template-spike"}},
+				// Reported once per window
+				{2*time.Hour + time.Minute, false, repeatLines("WARN retrying job %d", 30), nil},
+				// The spiking window raised the rate
+				{2*time.Hour + 6*time.Minute, false, repeatLines("WARN retrying job %d", 30), nil},
+			},
+		},
+		{
+			name: "spike below the minimum count",
+			steps: []step{
+				{0, true, "WARN retrying job 1", nil},
+				{2 * time.Hour, false, repeatLines("WARN retrying job %d", 19), nil},
+			},
+		},
+		{
+			name: "rate counted per window, not per analysis",
+			steps: []step{
+				{0, true, repeatLines("WARN retrying job %d", 5), nil},
+				// Many analyses in one window add up to a spike
+				{2 * time.Hour, true, repeatLines("WARN retrying job %d", 10), nil},
+				{2*time.Hour + time.Minute, true, repeatLines("WARN retrying job %d", 10), []string{"template-spike"}},
+			},
+		},
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	workload := Workload{Kind: "Deployment", Name: "api"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewTemplateStore("", time.Hour, 5*time.Minute, 0.5, 10, 20)
+			for i, step := range tt.steps {
+				anomalies, err := store.Observe("shop", workload, step.logs, step.healthy, start.Add(step.at))
+				if err != nil {
+					t.Fatalf("step %d: Observe() error = %v", i, err)
+				}
+				var got []string
+				for _, anomaly := range anomalies {
+					got = append(got, anomaly.RuleID)
+				}
+				if !slices.Equal(got, step.want) {
+					t.Errorf("step %d: Observe() = %v, want rules %v", i, FailureMessages(anomalies), step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateStoreWorkloadKinds(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	store := NewTemplateStore(dir, time.Hour, 5*time.Minute, 0.5, 10, 20)
+	deployment := Workload{Kind: "Deployment", Name: "api"}
+	statefulSet := Workload{Kind: "StatefulSet", Name: "api"}
+	if _, err := store.Observe("shop", deployment, "INFO serving request 1", true, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Observe("shop", statefulSet, "INFO replica ready\nINFO snapshot taken", true, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store reads the templates back from dir
+	reloaded := NewTemplateStore(dir, time.Hour, 5*time.Minute, 0.5, 10, 20)
+	tests := []struct {
+		workload Workload
+		want     int
+	}{
+		{deployment, 1},
+		{statefulSet, 2},
+		{Workload{Kind: "DaemonSet", Name: "api"}, 0},
+	}
+	for _, tt := range tests {
+		templates, err := reloaded.Templates("shop", tt.workload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(templates) != tt.want {
+			t.Errorf("Templates(%s) = %d templates, want %d", tt.workload, len(templates), tt.want)
+		}
+	}
+
+	workloads, err := reloaded.Workloads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, workload := range workloads {
+		got = append(got, workload.Namespace+"/"+workload.Workload.String())
+	}
+	if want := []string{"shop/Deployment/api", "shop/StatefulSet/api"}; !slices.Equal(got, want) {
+		t.Errorf("Workloads() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxEventLines caps how many lines a single event can absorb so a runaway
+// goroutine dump cannot swallow the rest of the log.
+const maxEventLines = 200
+
+var (
+	stackFrameRe    = regexp.MustCompile(`^\s+(at |\.\.\. \d+ more|File ")`)
+	causedByRe      = regexp.MustCompile(`^(Caused by:|\s*Suppressed:|During handling of the above exception|The above exception was the direct cause)`)
+	javaExceptionRe = regexp.MustCompile(`^([a-zA-Z_$][\w$]*\.)+[\w$]*(Exception|Error|Throwable)(:|$)`)
+	goPanicRe       = regexp.MustCompile(`^(panic: |fatal error: |SIG[A-Z]+: |goroutine \d+ \[)`)
+	pyTracebackRe   = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	pyExceptionRe   = regexp.MustCompile(`^[A-Za-z_][\w.]*(Error|Exception|Exit|Interrupt)\b`)
+	newLogEntryRe   = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|[IWEF]\d{4} |\{|\[|(level|time|ts)=)`)
+	indentedLineRe  = regexp.MustCompile(`^(\t| {2,})\S`)
+)
+
+// LogEvent is one logical log entry: a line plus the continuation lines that
+// belong to it, such as stack frames or a traceback.
+type LogEvent struct {
+	Lines     []string
+	StartLine int       // 1-based line number of the first line
+	Timestamp time.Time // from the first line's timestamp prefix, if any
+}
+
+func (e LogEvent) Text() string {
+	return strings.Join(e.Lines, "\n")
+}
+
+type multilineMode int
+
+const (
+	modeDefault multilineMode = iota
+	modeGoPanic
+	modePythonTraceback
+)
+
+// MultilineAssembler groups log lines into events as they arrive. Feed it
+// lines with Add and call Flush when the input ends or goes idle.
+type MultilineAssembler struct {
+	current *LogEvent
+	mode    multilineMode
+	lineNo  int
+}
+
+func NewMultilineAssembler() *MultilineAssembler {
+	return &MultilineAssembler{}
+}
+
+// Add consumes one line and returns the events completed by it. A timestamp
+// prefix is stripped from the line and kept on the event.
+func (m *MultilineAssembler) Add(line string) []LogEvent {
+	m.lineNo++
+	timestamp, line := SplitLogTimestamp(strings.TrimRight(line, "\r"))
+
+	if m.current != nil && len(m.current.Lines) < maxEventLines && m.isContinuation(line) {
+		m.current.Lines = append(m.current.Lines, line)
+		return nil
+	}
+
+	completed := m.Flush()
+	if strings.TrimSpace(line) == "" {
+		return completed
+	}
+	m.current = &LogEvent{Lines: []string{line}, StartLine: m.lineNo, Timestamp: timestamp}
+	m.mode = modeFor(line)
+	return completed
+}
+
+// Flush returns the pending event, if any.
+func (m *MultilineAssembler) Flush() []LogEvent {
+	if m.current == nil {
+		return nil
+	}
+	event := *m.current
+	m.current = nil
+	m.mode = modeDefault
+
+	// Trailing blank lines are only kept while a trace may still continue
+	for len(event.Lines) > 1 && strings.TrimSpace(event.Lines[len(event.Lines)-1]) == "" {
+		event.Lines = event.Lines[:len(event.Lines)-1]
+	}
+	return []LogEvent{event}
+}
+
+func (m *MultilineAssembler) isContinuation(line string) bool {
+	switch m.mode {
+	case modeGoPanic:
+		// A Go panic is followed by blank lines, goroutine headers and
+		// unindented function names, so everything up to the next log entry
+		// belongs to it, including the "[signal ...]" line of a crash
+		return strings.HasPrefix(line, "[signal ") || !newLogEntryRe.MatchString(line)
+	case modePythonTraceback:
+		// Blank lines and "During handling of the above exception" keep
+		// chained tracebacks together in one event
+		return strings.TrimSpace(line) == "" || indentedLineRe.MatchString(line) ||
+			pyTracebackRe.MatchString(line) || causedByRe.MatchString(line) ||
+			pyExceptionRe.MatchString(line)
+	}
+
+	switch {
+	case stackFrameRe.MatchString(line), causedByRe.MatchString(line), indentedLineRe.MatchString(line):
+		return true
+	case javaExceptionRe.MatchString(line):
+		return true
+	case pyTracebackRe.MatchString(line):
+		// Python's logging module prints the traceback right after the
+		// message passed to logger.exception
+		m.mode = modePythonTraceback
+		return true
+	}
+	return false
+}
+
+func modeFor(line string) multilineMode {
+	switch {
+	case goPanicRe.MatchString(line):
+		return modeGoPanic
+	case pyTracebackRe.MatchString(line):
+		return modePythonTraceback
+	}
+	return modeDefault
+}
+
+// AssembleEvents splits a log blob into events.
+func AssembleEvents(logs string) []LogEvent {
+	assembler := NewMultilineAssembler()
+	var events []LogEvent
+	for _, line := range strings.Split(logs, "\n") {
+		events = append(events, assembler.Add(line)...)
+	}
+	return append(events, assembler.Flush()...)
+}
@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssembleEvents(t *testing.T) {
+	type event struct {
+		start int
+		lines []string
+	}
+	tests := []struct {
+		name string
+		logs string
+		want []event
+	}{
+		{
+			name: "single lines",
+			logs: "INFO starting\nERROR failed\n",
+			want: []event{{1, []string{"INFO starting"}}, {2, []string{"ERROR failed"}}},
+		},
+		{
+			name: "blank lines between entries",
+			logs: "INFO one\n\n\nINFO two",
+			want: []event{{1, []string{"INFO one"}}, {4, []string{"INFO two"}}},
+		},
+		{
+			name: "java stack trace",
+			logs: `ERROR request failed
+java.lang.RuntimeException: checkout failed
+	at com.acme.shop.Checkout.run(Checkout.java:30)
+Caused by: java.sql.SQLTimeoutException: query timed out
+	at org.postgresql.jdbc.PgStatement.execute(PgStatement.java:120)
+	... 15 more
+	Suppressed: java.io.IOException: close failed
+INFO next request`,
+			want: []event{
+				{1, []string{
+					"ERROR request failed",
+					"java.lang.RuntimeException: checkout failed",
+					"\tat com.acme.shop.Checkout.run(Checkout.java:30)",
+					"Caused by: java.sql.SQLTimeoutException: query timed out",
+					"\tat org.postgresql.jdbc.PgStatement.execute(PgStatement.java:120)",
+					"\t... 15 more",
+					"\tSuppressed: java.io.IOException: close failed",
+				}},
+				{8, []string{"INFO next request"}},
+			},
+		},
+		{
+			name: "go panic",
+			logs: `panic: runtime error: index out of range [3] with length 3
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]
+
+goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x25
+
+goroutine 6 [select]:
+net/http.(*Server).Serve(...)
+
+2024-05-01 12:00:00 INFO restarted`,
+			want: []event{
+				{1, []string{
+					"panic: runtime error: index out of range [3] with length 3",
+					"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]",
+					"",
+					"goroutine 1 [running]:",
+					"main.main()",
+					"\t/src/main.go:10 +0x25",
+					"",
+					"goroutine 6 [select]:",
+					"net/http.(*Server).Serve(...)",
+				}},
+				{11, []string{"2024-05-01 12:00:00 INFO restarted"}},
+			},
+		},
+		{
+			name: "go panic ended by a json entry",
+			logs: "fatal error: concurrent map writes\n\ngoroutine 12 [running]:\n{\"level\":\"info\",\"msg\":\"up\"}",
+			want: []event{
+				{1, []string{"fatal error: concurrent map writes", "", "goroutine 12 [running]:"}},
+				{4, []string{`{"level":"info","msg":"up"}`}},
+			},
+		},
+		{
+			name: "chained python tracebacks",
+			logs: `Traceback (most recent call last):
+  File "/app/shop/db.py", line 40, in connect
+    sock.connect(addr)
+ConnectionRefusedError: [Errno 111] Connection refused
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/shop/main.py", line 9, in <module>
+    db.connect()
+shop.db.DatabaseError: database unavailable
+INFO shutting down`,
+			want: []event{
+				{1, []string{
+					"Traceback (most recent call last):",
+					`  File "/app/shop/db.py", line 40, in connect`,
+					"    sock.connect(addr)",
+					"ConnectionRefusedError: [Errno 111] Connection refused",
+					"",
+					"During handling of the above exception, another exception occurred:",
+					"",
+					"Traceback (most recent call last):",
+					`  File "/app/shop/main.py", line 9, in <module>`,
+					"    db.connect()",
+					"shop.db.DatabaseError: database unavailable",
+				}},
+				{12, []string{"INFO shutting down"}},
+			},
+		},
+		{
+			name: "python traceback after a log message",
+			logs: `ERROR:root:checkout failed
+Traceback (most recent call last):
+  File "/app/shop/cart.py", line 12, in total
+    return sum(prices)
+TypeError: unsupported operand type(s) for +: 'int' and 'NoneType'
+
+INFO:root:next`,
+			want: []event{
+				{1, []string{
+					"ERROR:root:checkout failed",
+					"Traceback (most recent call last):",
+					`  File "/app/shop/cart.py", line 12, in total`,
+					"    return sum(prices)",
+					"TypeError: unsupported operand type(s) for +: 'int' and 'NoneType'",
+				}},
+				{7, []string{"INFO:root:next"}},
+			},
+		},
+		{
+			name: "node error",
+			logs: `TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/src/users.js:8:11)
+    at async /app/src/server.js:12:5
+Server listening on 8080`,
+			want: []event{
+				{1, []string{
+					"TypeError: Cannot read properties of undefined (reading 'id')",
+					"    at getUser (/app/src/users.js:8:11)",
+					"    at async /app/src/server.js:12:5",
+				}},
+				{4, []string{"Server listening on 8080"}},
+			},
+		},
+		{
+			name: "carriage returns",
+			logs: "ERROR failed\r\n\tat a.B.c(B.java:1)\r\n",
+			want: []event{{1, []string{"ERROR failed", "\tat a.B.c(B.java:1)"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := AssembleEvents(tt.logs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("AssembleEvents() = %d events %q, want %d", len(events), events, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if events[i].StartLine != want.start || !slices.Equal(events[i].Lines, want.lines) {
+					t.Errorf("event %d = line %d %q, want line %d %q", i, events[i].StartLine, events[i].Lines, want.start, want.lines)
+				}
+			}
+		})
+	}
+}
+
+func TestAssembleEventsLineLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string
+		line  string
+		total int
+		want  []int // lines per event
+	}{
+		{
+			name:  "trace at the limit",
+			first: "java.lang.StackOverflowError",
+			line:  "\tat com.acme.Recursion.call(Recursion.java:%d)",
+			total: maxEventLines,
+			want:  []int{maxEventLines},
+		},
+		{
+			name:  "trace over the limit",
+			first: "java.lang.StackOverflowError",
+			line:  "\tat com.acme.Recursion.call(Recursion.java:%d)",
+			total: maxEventLines + 50,
+			want:  []int{maxEventLines, 50},
+		},
+		{
+			name:  "goroutine dump over twice the limit",
+			first: "goroutine 1 [running]:",
+			line:  "main.worker%d()",
+			total: 2*maxEventLines + 1,
+			// The lines past the limit are no longer in a panic, so each
+			// unindented line is an entry of its own
+			want: append([]int{maxEventLines}, slices.Repeat([]int{1}, maxEventLines+1)...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []string{tt.first}
+			for i := 1; i < tt.total; i++ {
+				lines = append(lines, fmt.Sprintf(tt.line, i))
+			}
+			events := AssembleEvents(strings.Join(lines, "\n"))
+			var got []int
+			for _, event := range events {
+				got = append(got, len(event.Lines))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("AssembleEvents() event sizes = %v, want %v", got, tt.want)
+			}
+			if len(events) > 1 && events[1].StartLine != maxEventLines+1 {
+				t.Errorf("second event starts at line %d, want %d", events[1].StartLine, maxEventLines+1)
+			}
+		})
+	}
+}
+
+func TestMultilineAssemblerAdd(t *testing.T) {
+	assembler := NewMultilineAssembler()
+	steps := []struct {
+		line string
+		want []string // first lines of the completed events
+	}{
+		{"2024-05-01T12:00:00Z java.lang.IllegalStateException: boom", nil},
+		{"2024-05-01T12:00:00Z \tat com.acme.Shop.run(Shop.java:10)", nil},
+		{"2024-05-01T12:00:01Z INFO recovered", []string{"java.lang.IllegalStateException: boom"}},
+		{"", []string{"INFO recovered"}},
+		{"", nil},
+	}
+	for i, step := range steps {
+		var got []string
+		for _, event := range assembler.Add(step.line) {
+			got = append(got, event.Lines[0])
+		}
+		if !slices.Equal(got, step.want) {
+			t.Fatalf("Add(%q) at step %d completed %q, want %q", step.line, i, got, step.want)
+		}
+	}
+	if events := assembler.Flush(); events != nil {
+		t.Errorf("Flush() = %q, want no pending event", events)
+	}
+
+	assembler.Add("2024-05-01T12:00:02Z ERROR failed")
+	events := assembler.Flush()
+	if len(events) != 1 {
+		t.Fatalf("Flush() = %d events, want 1", len(events))
+	}
+	want := time.Date(2024, 5, 1, 12, 0, 2, 0, time.UTC)
+	if !events[0].Timestamp.Equal(want) || events[0].StartLine != 6 || events[0].Text() != "ERROR failed" {
+		t.Errorf("Flush() = line %d at %v %q, want line 6 at %v %q", events[0].StartLine, events[0].Timestamp, events[0].Text(), want, "ERROR failed")
+	}
+}
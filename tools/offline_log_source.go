@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	logFileSuffix         = ".log"
+	previousLogFileSuffix = ".previous.log"
+)
+
+// offlineLogs indexes collected log files laid out as
+// namespace/pod/container.log, with the previous instance's logs in
+// namespace/pod/container.previous.log. Any leading directories, such as the
+// top-level folder of a support bundle, are ignored.
+type offlineLogs struct {
+	files map[string]offlineLogFile // key: namespace/pod/container
+}
+
+type offlineLogFile struct {
+	current  string
+	previous string
+}
+
+// add registers a file under its namespace/pod/container key and returns
+// false when the path does not follow the layout.
+func (o *offlineLogs) add(filePath, ref string) bool {
+	parts := strings.Split(path.Clean(filepath.ToSlash(filePath)), "/")
+	if len(parts) < 3 {
+		return false
+	}
+	namespace, podName, fileName := parts[len(parts)-3], parts[len(parts)-2], parts[len(parts)-1]
+
+	previous := strings.HasSuffix(fileName, previousLogFileSuffix)
+	var containerName string
+	switch {
+	case previous:
+		containerName = strings.TrimSuffix(fileName, previousLogFileSuffix)
+	case strings.HasSuffix(fileName, logFileSuffix):
+		containerName = strings.TrimSuffix(fileName, logFileSuffix)
+	default:
+		return false
+	}
+	if containerName == "" {
+		return false
+	}
+
+	key := CursorKey(namespace, podName, containerName)
+	file := o.files[key]
+	if previous {
+		file.previous = ref
+	} else {
+		file.current = ref
+	}
+	o.files[key] = file
+	return true
+}
+
+func (o *offlineLogs) listContainers(namespace string, filter *ScanFilter) []SourceContainer {
+	var containers []SourceContainer
+	for key, file := range o.files {
+		if file.current == "" && file.previous == "" {
+			continue
+		}
+		parts := strings.SplitN(key, "/", 3)
+		if namespace != "" && parts[0] != namespace {
+			continue
+		}
+		// Only names are known offline, so label and field selectors cannot
+		// be applied
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: parts[0], Name: parts[1]}}
+		if !filter.MatchNamespace(parts[0]) || !filter.MatchContainer(pod, parts[2]) {
+			continue
+		}
+		containers = append(containers, SourceContainer{
+			Namespace:     parts[0],
+			PodName:       parts[1],
+			ContainerName: parts[2],
+			Kind:          ContainerKindRegular,
+		})
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return CursorKey(containers[i].Namespace, containers[i].PodName, containers[i].ContainerName) <
+			CursorKey(containers[j].Namespace, containers[j].PodName, containers[j].ContainerName)
+	})
+	return containers
+}
+
+// lookup returns the reference of the requested log file.
+func (o *offlineLogs) lookup(req LogRequest) (string, error) {
+	file := o.files[CursorKey(req.Namespace, req.PodName, req.ContainerName)]
+	ref := file.current
+	if req.Previous {
+		ref = file.previous
+	}
+	if ref == "" {
+		return "", fmt.Errorf("no logs collected for pod %s/%s container %s", req.Namespace, req.PodName, req.ContainerName)
+	}
+	return ref, nil
+}
+
+// DirLogSource reads logs from a directory of collected log files.
+type DirLogSource struct {
+	logs offlineLogs // refs are file paths
+}
+
+func NewDirLogSource(root string) (*DirLogSource, error) {
+	source := &DirLogSource{logs: offlineLogs{files: make(map[string]offlineLogFile)}}
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		source.logs.add(rel, filePath)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log directory %s: %w", root, err)
+	}
+	return source, nil
+}
+
+func (s *DirLogSource) ListContainers(ctx context.Context, namespace string, filter *ScanFilter) ([]SourceContainer, error) {
+	return s.logs.listContainers(namespace, filter), nil
+}
+
+func (s *DirLogSource) GetLogs(ctx context.Context, req LogRequest) (string, error) {
+	filePath, err := s.logs.lookup(req)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read log file: %w", err)
+	}
+	return selectLogLines(string(data), req), nil
+}
+
+// ArchiveLogSource reads logs from a tar.gz support bundle. The log files are
+// loaded into memory when the source is created.
+type ArchiveLogSource struct {
+	logs     offlineLogs // refs are keys into contents
+	contents map[string]string
+}
+
+func NewArchiveLogSource(archivePath string) (*ArchiveLogSource, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+	defer gz.Close()
+
+	source := &ArchiveLogSource{
+		logs:     offlineLogs{files: make(map[string]offlineLogFile)},
+		contents: make(map[string]string),
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+		}
+		if header.Typeflag != tar.TypeReg || !source.logs.add(header.Name, header.Name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+		source.contents[header.Name] = string(data)
+	}
+	return source, nil
+}
+
+func (s *ArchiveLogSource) ListContainers(ctx context.Context, namespace string, filter *ScanFilter) ([]SourceContainer, error) {
+	return s.logs.listContainers(namespace, filter), nil
+}
+
+func (s *ArchiveLogSource) GetLogs(ctx context.Context, req LogRequest) (string, error) {
+	name, err := s.logs.lookup(req)
+	if err != nil {
+		return "", err
+	}
+	return selectLogLines(s.contents[name], req), nil
+}
+
+// NewOfflineLogSource opens a directory or a .tar.gz/.tgz support bundle.
+func NewOfflineLogSource(logPath string) (LogSource, error) {
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log source: %w", err)
+	}
+	switch {
+	case info.IsDir():
+		source, err := NewDirLogSource(logPath)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	case strings.HasSuffix(logPath, ".tar.gz") || strings.HasSuffix(logPath, ".tgz"):
+		source, err := NewArchiveLogSource(logPath)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+	return nil, fmt.Errorf("unsupported log source %s: expected a directory or a .tar.gz archive", logPath)
+}
+
+// selectLogLines applies SinceTime and TailLines to collected logs. Lines are
+// only filtered by time when they start with an RFC3339 timestamp, as written
+// by kubectl logs --timestamps.
+func selectLogLines(logs string, req LogRequest) string {
+	if logs == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	if !req.SinceTime.IsZero() {
+		for i, line := range lines {
+			ts, _, _ := strings.Cut(line, " ")
+			t, err := time.Parse(time.RFC3339Nano, ts)
+			if err != nil || !t.Before(req.SinceTime) {
+				lines = lines[i:]
+				break
+			}
+			if i == len(lines)-1 {
+				lines = nil
+			}
+		}
+	}
+	if req.TailLines > 0 && int64(len(lines)) > req.TailLines {
+		lines = lines[int64(len(lines))-req.TailLines:]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
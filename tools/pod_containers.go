@@ -0,0 +1,80 @@
+package tools
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ContainerKind tells which part of the pod spec a container comes from.
+type ContainerKind string
+
+const (
+	ContainerKindInit      ContainerKind = "init"
+	ContainerKindRegular   ContainerKind = "regular"
+	ContainerKindEphemeral ContainerKind = "ephemeral"
+)
+
+type ContainerRef struct {
+	Name string
+	Kind ContainerKind
+}
+
+type ContainerStatusResult struct {
+	Kind   ContainerKind
+	Status corev1.ContainerStatus
+	// Workload owning the pod, filled in by the k8s_container_status tool
+	Workload Workload
+	// Failures reported by the pod and container status, filled in by the
+	// k8s_container_status tool
+	Failures []StatusFailure
+}
+
+// PodContainers lists every container of a pod in start order: init
+// containers first, then regular containers, then ephemeral debug containers.
+func PodContainers(pod *corev1.Pod) []ContainerRef {
+	var refs []ContainerRef
+	for _, c := range pod.Spec.InitContainers {
+		refs = append(refs, ContainerRef{Name: c.Name, Kind: ContainerKindInit})
+	}
+	for _, c := range pod.Spec.Containers {
+		refs = append(refs, ContainerRef{Name: c.Name, Kind: ContainerKindRegular})
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		refs = append(refs, ContainerRef{Name: c.Name, Kind: ContainerKindEphemeral})
+	}
+	return refs
+}
+
+// PodContainerStatuses returns the statuses of every container of a pod
+// tagged with their kind.
+func PodContainerStatuses(pod *corev1.Pod) []ContainerStatusResult {
+	var results []ContainerStatusResult
+	for _, s := range pod.Status.InitContainerStatuses {
+		results = append(results, ContainerStatusResult{Kind: ContainerKindInit, Status: s})
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		results = append(results, ContainerStatusResult{Kind: ContainerKindRegular, Status: s})
+	}
+	for _, s := range pod.Status.EphemeralContainerStatuses {
+		results = append(results, ContainerStatusResult{Kind: ContainerKindEphemeral, Status: s})
+	}
+	return results
+}
+
+// FindContainerStatus looks up a container's status in any of the pod's
+// status lists.
+func FindContainerStatus(pod *corev1.Pod, containerName string) (ContainerStatusResult, bool) {
+	for _, result := range PodContainerStatuses(pod) {
+		if result.Status.Name == containerName {
+			return result, true
+		}
+	}
+	return ContainerStatusResult{}, false
+}
+
+// IsBlockedByInitContainers reports whether a regular container is waiting
+// because the pod's init containers have not completed yet.
+func IsBlockedByInitContainers(result ContainerStatusResult) bool {
+	return result.Kind == ContainerKindRegular &&
+		result.Status.State.Waiting != nil &&
+		result.Status.State.Waiting.Reason == "PodInitializing"
+}
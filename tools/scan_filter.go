@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// IgnoreAnnotation set to "true" on a pod opts it out of monitoring.
+	IgnoreAnnotation = "logmonitor/ignore"
+	// IgnoreContainersAnnotation lists container names of a pod to skip.
+	IgnoreContainersAnnotation = "logmonitor/ignore-containers"
+)
+
+// DefaultExcludeNamespaces keeps control plane noise out of scans unless
+// MONITOR_EXCLUDE_NAMESPACES says otherwise.
+var DefaultExcludeNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// ScanFilter decides which namespaces, pods and containers are monitored.
+// Namespace and container lists accept path.Match globs such as "team-*".
+// A nil *ScanFilter matches everything.
+type ScanFilter struct {
+	IncludeNamespaces []string
+	ExcludeNamespaces []string
+	LabelSelector     labels.Selector
+	FieldSelector     fields.Selector
+	ExcludeContainers []string
+}
+
+// ScanFilterFromEnv builds a ScanFilter from comma-separated environment
+// variables: MONITOR_INCLUDE_NAMESPACES, MONITOR_EXCLUDE_NAMESPACES,
+// MONITOR_LABEL_SELECTOR, MONITOR_FIELD_SELECTOR and
+// MONITOR_EXCLUDE_CONTAINERS.
+func ScanFilterFromEnv() (*ScanFilter, error) {
+	filter := &ScanFilter{
+		IncludeNamespaces: splitList(os.Getenv("MONITOR_INCLUDE_NAMESPACES")),
+		ExcludeNamespaces: DefaultExcludeNamespaces,
+		ExcludeContainers: splitList(os.Getenv("MONITOR_EXCLUDE_CONTAINERS")),
+		LabelSelector:     labels.Everything(),
+		FieldSelector:     fields.Everything(),
+	}
+	if exclude, set := os.LookupEnv("MONITOR_EXCLUDE_NAMESPACES"); set {
+		filter.ExcludeNamespaces = splitList(exclude)
+	}
+
+	patterns := append([]string{}, filter.IncludeNamespaces...)
+	patterns = append(patterns, filter.ExcludeNamespaces...)
+	patterns = append(patterns, filter.ExcludeContainers...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+
+	if selector := os.Getenv("MONITOR_LABEL_SELECTOR"); selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MONITOR_LABEL_SELECTOR: %w", err)
+		}
+		filter.LabelSelector = parsed
+	}
+	if selector := os.Getenv("MONITOR_FIELD_SELECTOR"); selector != "" {
+		parsed, err := fields.ParseSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MONITOR_FIELD_SELECTOR: %w", err)
+		}
+		filter.FieldSelector = parsed
+	}
+	return filter, nil
+}
+
+// ListOptions carries the label and field selectors to the API server so
+// filtered pods are never transferred.
+func (f *ScanFilter) ListOptions() metav1.ListOptions {
+	var opts metav1.ListOptions
+	if f == nil {
+		return opts
+	}
+	if f.LabelSelector != nil && !f.LabelSelector.Empty() {
+		opts.LabelSelector = f.LabelSelector.String()
+	}
+	if f.FieldSelector != nil && !f.FieldSelector.Empty() {
+		opts.FieldSelector = f.FieldSelector.String()
+	}
+	return opts
+}
+
+// TweakListOptions applies the selectors to informer list/watch calls.
+func (f *ScanFilter) TweakListOptions(opts *metav1.ListOptions) {
+	filtered := f.ListOptions()
+	opts.LabelSelector = filtered.LabelSelector
+	opts.FieldSelector = filtered.FieldSelector
+}
+
+// Namespace returns the only namespace the filter can match when it
+// includes exactly one literal namespace, so lists and informers can be
+// scoped to it. Otherwise it returns "" for all namespaces.
+func (f *ScanFilter) Namespace() string {
+	if f == nil || len(f.IncludeNamespaces) != 1 || strings.ContainsAny(f.IncludeNamespaces[0], "*?[") {
+		return ""
+	}
+	return f.IncludeNamespaces[0]
+}
+
+func (f *ScanFilter) MatchNamespace(namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.IncludeNamespaces) > 0 && !matchAny(f.IncludeNamespaces, namespace) {
+		return false
+	}
+	return !matchAny(f.ExcludeNamespaces, namespace)
+}
+
+// MatchPod applies the namespace lists, the opt-out annotation and the
+// selectors. Selectors are checked again here because informer caches and
+// offline sources are not always filtered server-side.
+func (f *ScanFilter) MatchPod(pod *corev1.Pod) bool {
+	if f == nil {
+		return true
+	}
+	if !f.MatchNamespace(pod.Namespace) || pod.Annotations[IgnoreAnnotation] == "true" {
+		return false
+	}
+	if f.LabelSelector != nil && !f.LabelSelector.Matches(labels.Set(pod.Labels)) {
+		return false
+	}
+	if f.FieldSelector != nil && !f.FieldSelector.Matches(podFields(pod)) {
+		return false
+	}
+	return true
+}
+
+// MatchContainer applies the global container exclusions and the pod's
+// ignore-containers annotation.
+func (f *ScanFilter) MatchContainer(pod *corev1.Pod, containerName string) bool {
+	if f == nil {
+		return true
+	}
+	if matchAny(f.ExcludeContainers, containerName) {
+		return false
+	}
+	return !matchAny(splitList(pod.Annotations[IgnoreContainersAnnotation]), containerName)
+}
+
+// Containers returns the pod's monitored containers, or nil when the pod
+// itself is filtered out.
+func (f *ScanFilter) Containers(pod *corev1.Pod) []ContainerRef {
+	if !f.MatchPod(pod) {
+		return nil
+	}
+	var refs []ContainerRef
+	for _, ref := range PodContainers(pod) {
+		if f.MatchContainer(pod, ref.Name) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// ContainerStatuses returns the statuses of the pod's monitored containers.
+func (f *ScanFilter) ContainerStatuses(pod *corev1.Pod) []ContainerStatusResult {
+	if !f.MatchPod(pod) {
+		return nil
+	}
+	var results []ContainerStatusResult
+	for _, result := range PodContainerStatuses(pod) {
+		if f.MatchContainer(pod, result.Status.Name) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// podFields exposes the pod fields the API server supports in field
+// selectors.
+func podFields(pod *corev1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.Name,
+		"metadata.namespace":       pod.Namespace,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func filterPod(namespace string, labels, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "api-0", Labels: labels, Annotations: annotations},
+		Spec: corev1.PodSpec{
+			NodeName:       "node-1",
+			InitContainers: []corev1.Container{{Name: "migrate"}},
+			Containers:     []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestScanFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		pod  *corev1.Pod
+		want []string // monitored containers, nil when the pod is filtered out
+	}{
+		{
+			name: "defaults",
+			pod:  filterPod("shop", nil, nil),
+			want: []string{"migrate", "app", "istio-proxy"},
+		},
+		{
+			name: "default excluded namespace",
+			pod:  filterPod("kube-system", nil, nil),
+		},
+		{
+			name: "excluded namespaces replaced",
+			env:  map[string]string{"MONITOR_EXCLUDE_NAMESPACES": ""},
+			pod:  filterPod("kube-system", nil, nil),
+			want: []string{"migrate", "app", "istio-proxy"},
+		},
+		{
+			name: "included namespace glob",
+			env:  map[string]string{"MONITOR_INCLUDE_NAMESPACES": "team-*, shop"},
+			pod:  filterPod("team-payments", nil, nil),
+			want: []string{"migrate", "app", "istio-proxy"},
+		},
+		{
+			name: "namespace not included",
+			env:  map[string]string{"MONITOR_INCLUDE_NAMESPACES": "team-*"},
+			pod:  filterPod("shop", nil, nil),
+		},
+		{
+			name: "excluded namespace glob",
+			env:  map[string]string{"MONITOR_EXCLUDE_NAMESPACES": "*-staging"},
+			pod:  filterPod("shop-staging", nil, nil),
+		},
+		{
+			name: "label selector",
+			env:  map[string]string{"MONITOR_LABEL_SELECTOR": "tier=backend,team!=data"},
+			pod:  filterPod("shop", map[string]string{"tier": "backend"}, nil),
+			want: []string{"migrate", "app", "istio-proxy"},
+		},
+		{
+			name: "label selector not matching",
+			env:  map[string]string{"MONITOR_LABEL_SELECTOR": "tier=backend"},
+			pod:  filterPod("shop", map[string]string{"tier": "frontend"}, nil),
+		},
+		{
+			name: "field selector",
+			env:  map[string]string{"MONITOR_FIELD_SELECTOR": "spec.nodeName=node-2"},
+			pod:  filterPod("shop", nil, nil),
+		},
+		{
+			name: "opted-out pod",
+			pod:  filterPod("shop", nil, map[string]string{IgnoreAnnotation: "true"}),
+		},
+		{
+			name: "excluded container glob",
+			env:  map[string]string{"MONITOR_EXCLUDE_CONTAINERS": "istio-*"},
+			pod:  filterPod("shop", nil, nil),
+			want: []string{"migrate", "app"},
+		},
+		{
+			name: "opted-out containers",
+			pod:  filterPod("shop", nil, map[string]string{IgnoreContainersAnnotation: "migrate, istio-proxy"}),
+			want: []string{"app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MONITOR_INCLUDE_NAMESPACES", "MONITOR_LABEL_SELECTOR", "MONITOR_FIELD_SELECTOR", "MONITOR_EXCLUDE_CONTAINERS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if exclude, ok := tt.env["MONITOR_EXCLUDE_NAMESPACES"]; ok {
+				t.Setenv("MONITOR_EXCLUDE_NAMESPACES", exclude)
+			}
+			filter, err := ScanFilterFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ref := range filter.Containers(tt.pod) {
+				got = append(got, ref.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Containers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanFilterFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+	}{
+		{"MONITOR_INCLUDE_NAMESPACES", "team-["},
+		{"MONITOR_EXCLUDE_CONTAINERS", "[sidecar"},
+		{"MONITOR_LABEL_SELECTOR", "tier in (backend"},
+		{"MONITOR_FIELD_SELECTOR", "spec.nodeName"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := ScanFilterFromEnv(); err == nil {
+				t.Errorf("ScanFilterFromEnv() with %s=%q succeeded", tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestScanFilterNamespace(t *testing.T) {
+	tests := []struct {
+		include []string
+		want    string
+	}{
+		{[]string{"shop"}, "shop"},
+		{[]string{"team-*"}, ""},
+		{[]string{"shop", "billing"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		filter := &ScanFilter{IncludeNamespaces: tt.include}
+		if got := filter.Namespace(); got != tt.want {
+			t.Errorf("Namespace() with %v = %q, want %q", tt.include, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// StatusFailureType classifies a failure read from the pod status.
+type StatusFailureType string
+
+const (
+	StatusFailureImagePull       StatusFailureType = "image_pull"
+	StatusFailureContainerConfig StatusFailureType = "container_config"
+	StatusFailureContainerCreate StatusFailureType = "container_create"
+	StatusFailureCrashLoop       StatusFailureType = "crash_loop"
+	StatusFailureOOMKilled       StatusFailureType = "oom_killed"
+	StatusFailureErrorExit       StatusFailureType = "error_exit"
+	StatusFailureUnschedulable   StatusFailureType = "unschedulable"
+	StatusFailureEvicted         StatusFailureType = "evicted"
+	StatusFailureDeadline        StatusFailureType = "deadline_exceeded"
+)
+
+// recentTermination is how long a container that is running again still
+// reports its last termination, so a restart from days ago is not a failure.
+const recentTermination = 15 * time.Minute
+
+var waitingReasonTypes = map[string]StatusFailureType{
+	"ErrImagePull":               StatusFailureImagePull,
+	"ImagePullBackOff":           StatusFailureImagePull,
+	"ErrImageNeverPull":          StatusFailureImagePull,
+	"InvalidImageName":           StatusFailureImagePull,
+	"RegistryUnavailable":        StatusFailureImagePull,
+	"CreateContainerConfigError": StatusFailureContainerConfig,
+	"CreateContainerError":       StatusFailureContainerCreate,
+	"RunContainerError":          StatusFailureContainerCreate,
+	"PreStartHookError":          StatusFailureContainerCreate,
+	"PostStartHookError":         StatusFailureContainerCreate,
+	"CrashLoopBackOff":           StatusFailureCrashLoop,
+}
+
+// statusFailureClasses gives status failures the category and severity of
+// the matching failure rules, so they rank next to failures found in logs.
+var statusFailureClasses = map[StatusFailureType]struct {
+	category string
+	severity Severity
+}{
+	StatusFailureImagePull:       {"image", SeverityHigh},
+	StatusFailureContainerConfig: {"config", SeverityHigh},
+	StatusFailureContainerCreate: {"config", SeverityHigh},
+	StatusFailureCrashLoop:       {"crash", SeverityHigh},
+	StatusFailureOOMKilled:       {"oom", SeverityCritical},
+	StatusFailureErrorExit:       {"crash", SeverityHigh},
+	StatusFailureUnschedulable:   {"scheduling", SeverityHigh},
+	StatusFailureEvicted:         {"resources", SeverityHigh},
+	StatusFailureDeadline:        {"timeout", SeverityMedium},
+}
+
+// StatusFailure is a failure reported by Kubernetes itself rather than found
+// in the logs, such as an image that cannot be pulled or an OOM kill.
+type StatusFailure struct {
+	Type      StatusFailureType
+	Container string // empty for pod-level failures
+	Reason    string
+	Message   string
+	ExitCode  int32
+	Previous  bool      // from the last terminated instance
+	Time      time.Time // when the status was recorded, zero when unknown
+}
+
+func (f StatusFailure) String() string {
+	s := fmt.Sprintf("[%s] %s", f.Type, f.Reason)
+	if f.Type == StatusFailureOOMKilled || f.Type == StatusFailureErrorExit {
+		if signal := signalNames[f.ExitCode-128]; signal != "" {
+			s += fmt.Sprintf(" (exit code %d, %s)", f.ExitCode, signal)
+		} else {
+			s += fmt.Sprintf(" (exit code %d)", f.ExitCode)
+		}
+	}
+	if f.Previous {
+		s += " in previous instance"
+	}
+	if f.Message != "" {
+		s += ": " + f.Message
+	}
+	return s
+}
+
+// Failure converts the status failure for reporting next to log failures.
+func (f StatusFailure) Failure() Failure {
+	class := statusFailureClasses[f.Type]
+	return Failure{
+		Message:   f.String(),
+		Category:  class.category,
+		Severity:  class.severity,
+		FirstSeen: f.Time,
+		LastSeen:  f.Time,
+		Count:     1,
+	}
+}
+
+// DetectStatusFailures reads the pod's phase reason and conditions and the
+// state of one of its containers, and returns the failures they report. It
+// does not depend on logs, so it also covers containers that never started.
+func DetectStatusFailures(pod *corev1.Pod, containerName string) []StatusFailure {
+	failures := podStatusFailures(pod)
+	if result, found := FindContainerStatus(pod, containerName); found {
+		failures = append(failures, ContainerStatusFailures(result.Status)...)
+	}
+	return failures
+}
+
+func podStatusFailures(pod *corev1.Pod) []StatusFailure {
+	var failures []StatusFailure
+	switch pod.Status.Reason {
+	case "Evicted":
+		failures = append(failures, StatusFailure{Type: StatusFailureEvicted, Reason: pod.Status.Reason, Message: pod.Status.Message})
+	case "DeadlineExceeded":
+		failures = append(failures, StatusFailure{Type: StatusFailureDeadline, Reason: pod.Status.Reason, Message: pod.Status.Message})
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			failures = append(failures, StatusFailure{
+				Type:    StatusFailureUnschedulable,
+				Reason:  condition.Reason,
+				Message: condition.Message,
+				Time:    condition.LastTransitionTime.Time,
+			})
+		}
+	}
+	return failures
+}
+
+// ContainerStatusFailures returns the failures reported by a container's
+// current state and, after a recent restart, by its last termination.
+func ContainerStatusFailures(status corev1.ContainerStatus) []StatusFailure {
+	var failures []StatusFailure
+	if waiting := status.State.Waiting; waiting != nil {
+		if failureType, ok := waitingReasonTypes[waiting.Reason]; ok {
+			failures = append(failures, StatusFailure{
+				Type:      failureType,
+				Container: status.Name,
+				Reason:    waiting.Reason,
+				Message:   waiting.Message,
+			})
+		}
+	}
+	if failure, ok := terminationFailure(status.Name, status.State.Terminated, false); ok {
+		failures = append(failures, failure)
+	}
+	if last := status.LastTerminationState.Terminated; last != nil &&
+		(status.State.Running == nil || time.Since(last.FinishedAt.Time) < recentTermination) {
+		if failure, ok := terminationFailure(status.Name, last, true); ok {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+func terminationFailure(containerName string, terminated *corev1.ContainerStateTerminated, previous bool) (StatusFailure, bool) {
+	if terminated == nil {
+		return StatusFailure{}, false
+	}
+	failure := StatusFailure{
+		Container: containerName,
+		Reason:    terminated.Reason,
+		Message:   terminated.Message,
+		ExitCode:  terminated.ExitCode,
+		Previous:  previous,
+		Time:      terminated.FinishedAt.Time,
+	}
+	switch {
+	case terminated.Reason == "OOMKilled":
+		failure.Type = StatusFailureOOMKilled
+	case terminated.ExitCode != 0:
+		failure.Type = StatusFailureErrorExit
+		if failure.Reason == "" {
+			failure.Reason = "Error"
+		}
+	default:
+		return StatusFailure{}, false
+	}
+	return failure, true
+}
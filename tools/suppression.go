@@ -0,0 +1,332 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SuppressionMatcher selects the failures a suppression or silence hides.
+// Every field that is set must match: Pattern is a case-insensitive regular
+// expression matched against the failure's log line (or its message when it
+// has none), Rules lists rule IDs, and Namespaces and Workloads are
+// path.Match globs such as "team-*".
+type SuppressionMatcher struct {
+	Pattern    string   `json:"pattern,omitempty"`
+	Rules      []string `json:"rules,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Workloads  []string `json:"workloads,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (m *SuppressionMatcher) compile() error {
+	if m.Pattern == "" && len(m.Rules) == 0 && len(m.Namespaces) == 0 && len(m.Workloads) == 0 {
+		return errors.New("one of pattern, rules, namespaces or workloads is required")
+	}
+	for _, glob := range append(slices.Clone(m.Namespaces), m.Workloads...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	if m.Pattern != "" {
+		re, err := regexp.Compile("(?i)" + m.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		m.re = re
+	}
+	return nil
+}
+
+// Matches reports whether failure, found in namespace for workload, is
+// selected.
+func (m *SuppressionMatcher) Matches(failure Failure, namespace string, workload Workload) bool {
+	if len(m.Rules) > 0 && !slices.Contains(m.Rules, failure.RuleID) {
+		return false
+	}
+	if len(m.Namespaces) > 0 && !matchAny(m.Namespaces, namespace) {
+		return false
+	}
+	if len(m.Workloads) > 0 && !matchAny(m.Workloads, workload.Name) {
+		return false
+	}
+	if m.re != nil {
+		text := failure.Line
+		if text == "" {
+			text = failure.Message
+		}
+		return m.re.MatchString(text)
+	}
+	return true
+}
+
+// Suppression is a configured allowlist entry for known noise. It is loaded
+// from the suppressions section of the rule files, without a namespace or
+// workload it applies everywhere.
+type Suppression struct {
+	ID string `json:"id"`
+	SuppressionMatcher
+	Reason string `json:"reason"`
+	// Disabled turns off the built-in suppression with the same ID
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (s *Suppression) compile() error {
+	if s.ID == "" {
+		return errors.New("suppression without id")
+	}
+	if s.Disabled {
+		return nil
+	}
+	if s.Reason == "" {
+		return fmt.Errorf("suppression %s: reason is required", s.ID)
+	}
+	if err := s.SuppressionMatcher.compile(); err != nil {
+		return fmt.Errorf("suppression %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// Silence hides matching failures until it expires. Silences are created
+// through the web API by someone dealing with a known problem, e.g. during a
+// migration, and carry who created them and why.
+type Silence struct {
+	ID string `json:"id"`
+	SuppressionMatcher
+	Reason    string    `json:"reason"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SilenceStore keeps the active silences, optionally in a JSON file so they
+// survive restarts and are shared with other processes reading the file.
+type SilenceStore struct {
+	mu       sync.Mutex
+	path     string
+	modTime  time.Time
+	silences []*Silence
+}
+
+// NewSilenceStore returns a store backed by the JSON file at path, or an
+// in-memory store when path is empty.
+func NewSilenceStore(path string) (*SilenceStore, error) {
+	store := &SilenceStore{path: path}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewSilenceStoreFromEnv keeps silences in the file named by SILENCE_FILE,
+// or in memory when it is unset.
+func NewSilenceStoreFromEnv() (*SilenceStore, error) {
+	return NewSilenceStore(os.Getenv("SILENCE_FILE"))
+}
+
+// reload reads the file when it changed since it was last read. The caller
+// must hold mu or own the store exclusively.
+func (s *SilenceStore) reload() error {
+	if s.path == "" {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read silence file %s: %w", s.path, err)
+	}
+	if !info.ModTime().After(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read silence file %s: %w", s.path, err)
+	}
+	var silences []*Silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return fmt.Errorf("failed to parse silence file %s: %w", s.path, err)
+	}
+	for _, silence := range silences {
+		if err := silence.compile(); err != nil {
+			return fmt.Errorf("invalid silence %s in %s: %w", silence.ID, s.path, err)
+		}
+	}
+	s.silences = silences
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *SilenceStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.silences, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode silences: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write silence file: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Active returns the silences that have not expired, dropping expired ones.
+func (s *SilenceStore) Active() ([]Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make([]*Silence, 0, len(s.silences))
+	for _, silence := range s.silences {
+		if silence.ExpiresAt.After(now) {
+			active = append(active, silence)
+		}
+	}
+	if len(active) != len(s.silences) {
+		s.silences = active
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	silences := make([]Silence, len(active))
+	for i, silence := range active {
+		silences[i] = *silence
+	}
+	return silences, nil
+}
+
+// Add validates silence, assigns it an ID and stores it.
+func (s *SilenceStore) Add(silence Silence) (Silence, error) {
+	if silence.Reason == "" || silence.Author == "" {
+		return Silence{}, errors.New("reason and author are required")
+	}
+	if !silence.ExpiresAt.After(time.Now()) {
+		return Silence{}, errors.New("expires_at must be in the future")
+	}
+	if err := silence.compile(); err != nil {
+		return Silence{}, err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return Silence{}, fmt.Errorf("failed to generate silence id: %w", err)
+	}
+	silence.ID = hex.EncodeToString(id)
+	silence.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Silence{}, err
+	}
+	s.silences = append(s.silences, &silence)
+	if err := s.save(); err != nil {
+		s.silences = s.silences[:len(s.silences)-1]
+		return Silence{}, err
+	}
+	return silence, nil
+}
+
+// Remove expires the silence with id early and reports whether it existed.
+func (s *SilenceStore) Remove(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+	i := slices.IndexFunc(s.silences, func(silence *Silence) bool { return silence.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	s.silences = slices.Delete(s.silences, i, i+1)
+	return true, s.save()
+}
+
+// SuppressionResult splits failures into those to report and those hidden by
+// a suppression or silence, with SuppressedBy explaining which.
+type SuppressionResult struct {
+	Kept       []Failure
+	Suppressed []Failure
+}
+
+// SuppressionTool hides known noise: the suppressions of the active rules
+// and the active silences.
+type SuppressionTool struct {
+	rules    RuleProvider
+	silences *SilenceStore
+}
+
+// NewSuppressionTool creates the suppression tool; silences may be nil.
+func NewSuppressionTool(rules RuleProvider, silences *SilenceStore) *SuppressionTool {
+	return &SuppressionTool{rules: rules, silences: silences}
+}
+
+func (t *SuppressionTool) Name() string {
+	return "failure_suppression"
+}
+
+// Execute filters input["failures"] ([]Failure) found in input["namespace"]
+// for input["workload"] (Workload) and returns a SuppressionResult.
+func (t *SuppressionTool) Execute(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	failures, ok := input["failures"].([]Failure)
+	if !ok {
+		return nil, errors.New("failures must be a []Failure")
+	}
+	namespace, _ := input["namespace"].(string)
+	workload, _ := input["workload"].(Workload)
+
+	var silences []Silence
+	if t.silences != nil {
+		var err error
+		silences, err = t.silences.Active()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result SuppressionResult
+	for _, failure := range failures {
+		failure.SuppressedBy = t.suppressedBy(failure, namespace, workload, silences)
+		if failure.SuppressedBy == "" {
+			result.Kept = append(result.Kept, failure)
+		} else {
+			result.Suppressed = append(result.Suppressed, failure)
+		}
+	}
+	return result, nil
+}
+
+// suppressedBy explains which suppression or silence hides failure, or
+// returns "" when none does.
+func (t *SuppressionTool) suppressedBy(failure Failure, namespace string, workload Workload, silences []Silence) string {
+	for _, suppression := range t.rules.Rules().Suppressions() {
+		if suppression.Matches(failure, namespace, workload) {
+			return fmt.Sprintf("suppression %s: %s", suppression.ID, suppression.Reason)
+		}
+	}
+	for _, silence := range silences {
+		if silence.Matches(failure, namespace, workload) {
+			return fmt.Sprintf("silence %s by %s until %s: %s", silence.ID, silence.Author,
+				silence.ExpiresAt.UTC().Format(time.RFC3339), silence.Reason)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilenceStoreExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	now := time.Now()
+	data, err := json.Marshal([]Silence{
+		{ID: "old", SuppressionMatcher: SuppressionMatcher{Namespaces: []string{"shop"}}, Reason: "migration", Author: "ops", ExpiresAt: now.Add(-time.Minute)},
+		{ID: "new", SuppressionMatcher: SuppressionMatcher{Namespaces: []string{"shop"}}, Reason: "migration", Author: "ops", ExpiresAt: now.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewSilenceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := store.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != "new" {
+		t.Fatalf("Active() = %v, want silence new", active)
+	}
+
+	// The expired silence is dropped from the file as well
+	reloaded, err := NewSilenceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.silences) != 1 {
+		t.Errorf("silence file holds %d silences, want 1", len(reloaded.silences))
+	}
+
+	if _, err := store.Add(Silence{SuppressionMatcher: SuppressionMatcher{Rules: []string{"oom"}}, Reason: "known", Author: "ops", ExpiresAt: now.Add(-time.Second)}); err == nil {
+		t.Error("Add() of an expired silence succeeded")
+	}
+	added, err := store.Add(Silence{SuppressionMatcher: SuppressionMatcher{Rules: []string{"oom"}}, Reason: "known", Author: "ops", ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := store.Remove(added.ID); err != nil || !removed {
+		t.Errorf("Remove(%s) = %v, %v, want true", added.ID, removed, err)
+	}
+	if removed, _ := store.Remove(added.ID); removed {
+		t.Errorf("Remove(%s) twice = true, want false", added.ID)
+	}
+}
+
+func TestSuppressedBy(t *testing.T) {
+	rules, err := BuildRuleSet([]RuleSource{{Name: "custom.yaml", Data: []byte(`
+suppressions:
+  - id: probe-noise
+    pattern: 'health ?check'
+    namespaces: ['team-*']
+    reason: Health checks during rollouts.
+  - id: zero-errors
+    disabled: true
+`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	silences := []Silence{{
+		ID:                 "s1",
+		SuppressionMatcher: SuppressionMatcher{Rules: []string{"oom-killed"}, Workloads: []string{"batch-*"}},
+		Reason:             "resizing",
+		Author:             "ops",
+		ExpiresAt:          expires,
+	}}
+	for i := range silences {
+		if err := silences[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		failure   Failure
+		namespace string
+		workload  string
+		want      string // prefix of the explanation
+	}{
+		{
+			name:      "built-in suppression",
+			failure:   Failure{Line: "request done, retrying after timeout", RuleID: "timeout"},
+			namespace: "shop",
+			want:      "suppression retry-after-timeout: ",
+		},
+		{
+			name:      "built-in suppression limited to other rules",
+			failure:   Failure{Line: "request done, retrying after timeout", RuleID: "oom-killed"},
+			namespace: "shop",
+		},
+		{
+			name:      "disabled built-in suppression",
+			failure:   Failure{Line: "finished with 0 errors", RuleID: "error-keyword"},
+			namespace: "shop",
+		},
+		{
+			name:      "custom suppression in a matching namespace",
+			failure:   Failure{Message: "ERROR healthcheck failed"},
+			namespace: "team-payments",
+			want:      "suppression probe-noise: Health checks during rollouts.",
+		},
+		{
+			name:      "custom suppression in another namespace",
+			failure:   Failure{Message: "ERROR healthcheck failed"},
+			namespace: "shop",
+		},
+		{
+			name:     "silence",
+			failure:  Failure{Message: "OOMKilled", RuleID: "oom-killed"},
+			workload: "batch-import",
+			want:     "silence s1 by ops until 2030-01-01T00:00:00Z: resizing",
+		},
+		{
+			name:     "silence for other workloads",
+			failure:  Failure{Message: "OOMKilled", RuleID: "oom-killed"},
+			workload: "api",
+		},
+	}
+	tool := NewSuppressionTool(staticRules{rules}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := Workload{Kind: "Deployment", Name: tt.workload}
+			got := tool.suppressedBy(tt.failure, tt.namespace, workload, silences)
+			if (tt.want == "") != (got == "") || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("suppressedBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
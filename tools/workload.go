@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workload is the controller a pod belongs to, such as a Deployment, or the
+// pod itself when it has no controller.
+type Workload struct {
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name"`
+}
+
+func (w Workload) String() string {
+	if w.Kind == "" {
+		return w.Name
+	}
+	return w.Kind + "/" + w.Name
+}
+
+// Generated name suffixes use the alphabet of k8s.io/apimachinery's
+// rand.SafeEncodeString.
+var (
+	replicaSetPodNameRe = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{6,10}-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+	generatedPodNameRe  = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+	ordinalPodNameRe    = regexp.MustCompile(`^(.+)-\d+$`)
+	replicaSetNameRe    = regexp.MustCompile(`^(.+)-[bcdfghjklmnpqrstvwxz2456789]{6,10}$`)
+	scheduledJobNameRe  = regexp.MustCompile(`^(.+)-\d{8,}$`)
+)
+
+// PodWorkload returns the workload owning pod. ReplicaSets are reported as
+// their Deployment and Jobs created by a CronJob as the CronJob, by name, so
+// a rollout or a new schedule keeps the same workload.
+func PodWorkload(pod *corev1.Pod) Workload {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return Workload{Kind: "Pod", Name: pod.Name}
+	}
+	return ControllerWorkload(owner.Kind, owner.Name)
+}
+
+// ControllerWorkload returns the workload a controller stands for, the way
+// PodWorkload reports a pod's controller: a Deployment's ReplicaSet as the
+// Deployment and a CronJob's Job as the CronJob, by name. Other objects, such
+// as the involved object of an event, are their own workload.
+func ControllerWorkload(kind, name string) Workload {
+	switch kind {
+	case "ReplicaSet":
+		if m := replicaSetNameRe.FindStringSubmatch(name); m != nil {
+			return Workload{Kind: "Deployment", Name: m[1]}
+		}
+	case "Job":
+		if m := scheduledJobNameRe.FindStringSubmatch(name); m != nil {
+			return Workload{Kind: "CronJob", Name: m[1]}
+		}
+	}
+	return Workload{Kind: kind, Name: name}
+}
+
+// WorkloadFromPodName guesses the workload from a generated pod name, for
+// collected logs that come without the pod object. The kind is left empty.
+func WorkloadFromPodName(podName string) Workload {
+	for _, re := range []*regexp.Regexp{replicaSetPodNameRe, generatedPodNameRe, ordinalPodNameRe} {
+		if m := re.FindStringSubmatch(podName); m != nil {
+			return Workload{Name: m[1]}
+		}
+	}
+	return Workload{Name: podName}
+}
@@ -0,0 +1,22 @@
+package tools
+
+import "testing"
+
+func TestControllerWorkload(t *testing.T) {
+	tests := []struct {
+		kind, name string
+		want       Workload
+	}{
+		{"ReplicaSet", "api-7d9f8b6c5d", Workload{Kind: "Deployment", Name: "api"}},
+		{"ReplicaSet", "api", Workload{Kind: "ReplicaSet", Name: "api"}},
+		{"Job", "backup-28571234", Workload{Kind: "CronJob", Name: "backup"}},
+		{"Job", "migrate", Workload{Kind: "Job", Name: "migrate"}},
+		{"StatefulSet", "db", Workload{Kind: "StatefulSet", Name: "db"}},
+		{"DaemonSet", "agent", Workload{Kind: "DaemonSet", Name: "agent"}},
+	}
+	for _, tt := range tests {
+		if got := ControllerWorkload(tt.kind, tt.name); got != tt.want {
+			t.Errorf("ControllerWorkload(%s, %s) = %s, want %s", tt.kind, tt.name, got, tt.want)
+		}
+	}
+}
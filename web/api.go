@@ -3,18 +3,28 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
-	"strings"
+	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/vasudevchavan/K8sLogmonitor/config"
+	"github.com/vasudevchavan/K8sLogmonitor/tools"
 )
 
-type PodFailure struct {
-	Namespace     string `json:"namespace"`
-	PodName       string `json:"pod_name"`
-	ContainerName string `json:"container_name"`
-	Failures      string `json:"failures"`
-	Recommendation string `json:"recommendation"`
+// WorkloadIncident is a failure shared by the pods of a workload, reported
+// once with the affected pods. A suppressed failure has suppressed_by set in
+// its details and no recommendation.
+type WorkloadIncident struct {
+	Fingerprint    string          `json:"fingerprint"`
+	Namespace      string          `json:"namespace"`
+	Workload       tools.Workload  `json:"workload"`
+	Pods           []string        `json:"pods"`
+	ContainerName  string          `json:"container_name"`
+	ContainerKind  string          `json:"container_kind"`
+	Failures       string          `json:"failures"`
+	FailureDetails []tools.Failure `json:"failure_details"`
+	RulesVersion   string          `json:"rules_version,omitempty"`
+	Recommendation string          `json:"recommendation"`
 }
 
 func (s *Server) monitorAllHandler(w http.ResponseWriter, r *http.Request) {
@@ -23,50 +33,164 @@ func (s *Server) monitorAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	k8sClient := s.k8sClient
-	
-	// Get all namespaces
-	namespaces, err := k8sClient.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	// The scan stops when the client goes away or the timeout expires
+	timeout := time.Duration(config.DefaultThresholds.ScanTimeoutMs) * time.Millisecond
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	incidents, results, err := s.engine.ScanNamespaceIncidents(ctx, s.engine.Namespace())
 	if err != nil {
-		http.Error(w, "Failed to list namespaces", http.StatusInternalServerError)
+		http.Error(w, "Failed to list pods", http.StatusInternalServerError)
 		return
 	}
+	for _, res := range results {
+		if res.Err != nil {
+			log.Printf("Agent execution failed for %s: %v", res.Target.Input(), res.Err)
+		}
+	}
 
-	var allFailures []PodFailure
+	allIncidents := []WorkloadIncident{}
+	for _, incident := range incidents {
+		recommendation := incident.Recommendation
+		if recommendation == "" && !incident.Suppressed() {
+			recommendation = "No recommendation available"
+		}
+
+		allIncidents = append(allIncidents, WorkloadIncident{
+			Fingerprint:    incident.Fingerprint,
+			Namespace:      incident.Namespace,
+			Workload:       incident.Workload,
+			Pods:           incident.Pods,
+			ContainerName:  incident.ContainerName,
+			ContainerKind:  string(incident.Kind),
+			Failures:       incident.Failure.String(),
+			FailureDetails: []tools.Failure{incident.Failure},
+			RulesVersion:   incident.RulesVersion,
+			Recommendation: recommendation,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allIncidents)
+}
+
+// SilenceRequest creates a silence that lasts Duration, e.g. "2h".
+type SilenceRequest struct {
+	tools.SuppressionMatcher
+	Reason   string `json:"reason"`
+	Author   string `json:"author"`
+	Duration string `json:"duration"`
+}
 
-	for _, ns := range namespaces.Items {
-		// Get all pods in namespace
-		pods, err := k8sClient.CoreV1().Pods(ns.Name).List(context.Background(), metav1.ListOptions{})
+// silencesHandler lists the active silences (GET), creates one (POST) and
+// expires one early (DELETE ?id=).
+func (s *Server) silencesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		silences, err := s.silences.Active()
 		if err != nil {
-			continue
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(silences)
+
+	case http.MethodPost:
+		var req SilenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			http.Error(w, "duration must be a positive duration such as 2h", http.StatusBadRequest)
+			return
+		}
+		silence, err := s.silences.Add(tools.Silence{
+			SuppressionMatcher: req.SuppressionMatcher,
+			Reason:             req.Reason,
+			Author:             req.Author,
+			ExpiresAt:          time.Now().Add(duration),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Silence %s created by %s until %s: %s", silence.ID, silence.Author, silence.ExpiresAt.Format(time.RFC3339), silence.Reason)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(silence)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		removed, err := s.silences.Remove(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "Silence not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Silence %s removed", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		for _, pod := range pods.Items {
-			for _, container := range pod.Spec.Containers {
-				input := strings.Join([]string{ns.Name, pod.Name, container.Name}, "|")
-				result, err := s.agent.Execute(context.Background(), input)
-				
-				if err == nil && result != "No failures detected" && strings.Contains(result, "Failures:") {
-					// Parse failures and recommendation from result
-					parts := strings.Split(result, "\nRecommendation: ")
-					failuresPart := parts[0]
-					recommendation := "No recommendation available"
-					if len(parts) > 1 {
-						recommendation = parts[1]
-					}
-					
-					allFailures = append(allFailures, PodFailure{
-						Namespace:      ns.Name,
-						PodName:        pod.Name,
-						ContainerName:  container.Name,
-						Failures:       failuresPart,
-						Recommendation: recommendation,
-					})
-				}
-			}
+// templatesHandler lists the workloads with learned log templates, or with
+// ?namespace=&kind=&workload= the templates of one workload.
+func (s *Server) templatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var result interface{}
+	if workload := query.Get("workload"); workload != "" {
+		templates, err := s.templates.Templates(query.Get("namespace"), tools.Workload{Kind: query.Get("kind"), Name: workload})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		if templates == nil {
+			http.Error(w, "No templates learned for workload", http.StatusNotFound)
+			return
+		}
+		result = templates
+	} else {
+		workloads, err := s.templates.Workloads()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = workloads
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
 
+// rulesHandler returns the active failure rules with their version (GET), or
+// reloads them from their sources right away (POST).
+func (s *Server) rulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		changed, err := s.detection.Reload(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if changed {
+			log.Printf("Failure rules reloaded: version %s", s.detection.Rules().Version())
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(allFailures)
-}
\ No newline at end of file
+	json.NewEncoder(w).Encode(s.detection.Status())
+}
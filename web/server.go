@@ -3,18 +3,28 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/vasudevchavan/K8sLogmonitor/adk"
 	"github.com/vasudevchavan/K8sLogmonitor/agents"
+	"github.com/vasudevchavan/K8sLogmonitor/config"
+	"github.com/vasudevchavan/K8sLogmonitor/detection"
 	"github.com/vasudevchavan/K8sLogmonitor/tools"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 type Server struct {
 	agent     *agents.LogMonitorAgent
+	engine    *agents.ScanEngine
+	filter    *tools.ScanFilter
+	detection *detection.Engine
+	silences  *tools.SilenceStore
+	templates *tools.TemplateStore
 	k8sClient *kubernetes.Clientset
 }
 
@@ -25,9 +35,13 @@ type MonitorRequest struct {
 }
 
 type MonitorResponse struct {
-	Success bool   `json:"success"`
-	Result  string `json:"result"`
-	Error   string `json:"error,omitempty"`
+	Success        bool            `json:"success"`
+	Result         string          `json:"result"`
+	Failures       []tools.Failure `json:"failures,omitempty"`
+	Suppressed     []tools.Failure `json:"suppressed,omitempty"`
+	RulesVersion   string          `json:"rules_version,omitempty"`
+	Recommendation string          `json:"recommendation,omitempty"`
+	Error          string          `json:"error,omitempty"`
 }
 
 func NewServer() (*Server, error) {
@@ -36,17 +50,51 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
+	filter, err := tools.ScanFilterFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	detectionEngine, err := detection.NewEngineFromEnv(context.Background(), k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	silences, err := tools.NewSilenceStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	templates := tools.NewTemplateStoreFromEnv()
+
+	rates, err := tools.NewErrorRateTrackerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds := config.DefaultThresholds
+	apiLimit := adk.NewLimiter(thresholds.APIConcurrency)
+
 	registry := adk.NewToolRegistry()
-	registry.RegisterTool("k8s_logs", tools.NewK8sTool(k8sClient))
-	registry.RegisterTool("k8s_context", tools.NewK8sContextTool(k8sClient))
-	registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool())
-	registry.RegisterTool("github_issues", tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")))
-	registry.RegisterTool("llm_recommendation", tools.NewLLMTool(os.Getenv("LLM_API_KEY")))
+	source := tools.NewAPILogSource(k8sClient)
+	registry.RegisterTool("k8s_logs", adk.WithLimit(tools.NewK8sTool(source, nil), apiLimit))
+	registry.RegisterTool("k8s_context", adk.WithLimit(tools.NewK8sContextTool(k8sClient), apiLimit))
+	registry.RegisterTool("k8s_container_status", adk.WithLimit(tools.NewContainerStatusTool(k8sClient), apiLimit))
+	registry.RegisterTool("failure_detection", tools.NewFailureDetectionTool(detectionEngine, rates))
+	registry.RegisterTool("failure_suppression", tools.NewSuppressionTool(detectionEngine, silences))
+	registry.RegisterTool("anomaly_detection", tools.NewAnomalyDetectionTool(templates))
+	registry.RegisterTool("github_issues", adk.WithLimit(tools.NewGitHubTool(os.Getenv("GITHUB_TOKEN")), adk.NewLimiter(thresholds.GitHubConcurrency)))
+	registry.RegisterTool("llm_recommendation", adk.WithLimit(tools.NewLLMTool(os.Getenv("LLM_API_KEY")), adk.NewLimiter(thresholds.LLMConcurrency)))
 
-	agent := agents.NewLogMonitorAgent(registry)
+	agent := agents.NewLogMonitorAgent(registry, detectionEngine, time.Duration(thresholds.RecommendationCacheMs)*time.Millisecond)
 
 	return &Server{
 		agent:     agent,
+		engine:    agents.NewScanEngine(source, agent, filter, thresholds.ScanWorkers),
+		filter:    filter,
+		detection: detectionEngine,
+		silences:  silences,
+		templates: templates,
 		k8sClient: k8sClient,
 	}, nil
 }
@@ -63,21 +111,49 @@ func (s *Server) monitorHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	input := strings.Join([]string{req.Namespace, req.PodName, req.ContainerName}, "|")
-	result, err := s.agent.Execute(context.Background(), input)
-
-	resp := MonitorResponse{
-		Success: err == nil,
-		Result:  result,
+	// A single container is analyzed only if the monitor selection, including
+	// the opt-out annotations, would have scanned it
+	var result *agents.AnalysisResult
+	err := s.checkMonitored(r.Context(), req)
+	if err == nil {
+		input := strings.Join([]string{req.Namespace, req.PodName, req.ContainerName}, "|")
+		result, err = s.agent.Analyze(r.Context(), input)
 	}
+
+	resp := MonitorResponse{Success: err == nil}
 	if err != nil {
 		resp.Error = err.Error()
+	} else {
+		resp.Result = result.String()
+		resp.Failures = result.Failures
+		resp.Suppressed = result.Suppressed
+		resp.RulesVersion = result.RulesVersion
+		resp.Recommendation = result.Recommendation
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// checkMonitored returns an error when the requested container is excluded by
+// the server's ScanFilter.
+func (s *Server) checkMonitored(ctx context.Context, req MonitorRequest) error {
+	if !s.filter.MatchNamespace(req.Namespace) {
+		return fmt.Errorf("namespace %s is not monitored", req.Namespace)
+	}
+	pod, err := s.k8sClient.CoreV1().Pods(req.Namespace).Get(ctx, req.PodName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s/%s: %w", req.Namespace, req.PodName, err)
+	}
+	if !s.filter.MatchPod(pod) {
+		return fmt.Errorf("pod %s/%s is not monitored", req.Namespace, req.PodName)
+	}
+	if !s.filter.MatchContainer(pod, req.ContainerName) {
+		return fmt.Errorf("container %s of pod %s/%s is not monitored", req.ContainerName, req.Namespace, req.PodName)
+	}
+	return nil
+}
+
 func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 	html := `<!DOCTYPE html>
 <html>
@@ -161,17 +237,20 @@ func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
             
             try {
                 const response = await fetch('/api/monitor-all');
-                const failures = await response.json();
+                const incidents = await response.json();
                 
-                if (failures.length === 0) {
+                if (incidents.length === 0) {
                     resultDiv.innerHTML = '<div class="result success"><h3>✅ All Clear!</h3><p>No pod failures detected across all namespaces.</p></div>';
                 } else {
-                    let html = '<div class="result"><h3>🚨 Failed Pods (' + failures.length + '):</h3>';
-                    failures.forEach(failure => {
+                    let html = '<div class="result"><h3>🚨 Failing Workloads (' + incidents.length + '):</h3>';
+                    incidents.forEach(incident => {
+                        const workload = incident.workload.kind ? incident.workload.kind + '/' + incident.workload.name : incident.workload.name;
                         html += '<div style="margin: 20px 0; padding: 20px; background: #ffebee; border-left: 4px solid #f44336; border-radius: 8px;">';
-                        html += '<h4 style="margin: 0 0 10px 0; color: #d32f2f;">🚫 ' + failure.namespace + '/' + failure.pod_name + '/' + failure.container_name + '</h4>';
-                        html += '<div style="background: #fff; padding: 10px; border-radius: 4px; margin: 10px 0;"><strong>Failures:</strong><br>' + failure.failures.replace(/Failures: /, '') + '</div>';
-                        html += '<div style="background: #e3f2fd; padding: 10px; border-radius: 4px; border-left: 3px solid #2196f3;"><strong>💡 Recommendation:</strong><br>' + failure.recommendation + '</div>';
+                        html += '<h4 style="margin: 0 0 10px 0; color: #d32f2f;">🚫 ' + incident.namespace + '/' + workload + '/' + incident.container_name + ' (' + incident.container_kind + ' container)</h4>';
+                        html += '<div style="margin: 10px 0;"><strong>Incident:</strong> ' + incident.fingerprint + '</div>';
+                        html += '<div style="margin: 10px 0;"><strong>Affected pods (' + incident.pods.length + '):</strong> ' + incident.pods.join(', ') + '</div>';
+                        html += '<div style="background: #fff; padding: 10px; border-radius: 4px; margin: 10px 0;"><strong>Failures:</strong><br>' + incident.failures.replace(/\n/g, '<br>') + '</div>';
+                        html += '<div style="background: #e3f2fd; padding: 10px; border-radius: 4px; border-left: 3px solid #2196f3;"><strong>💡 Recommendation:</strong><br>' + incident.recommendation + '</div>';
                         html += '</div>';
                     });
                     html += '</div>';
@@ -189,8 +268,14 @@ func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) Start(port string) error {
+	// Rule edits take effect without restarting the server
+	go s.detection.Watch(context.Background(), time.Duration(config.DefaultThresholds.RuleReloadMs)*time.Millisecond)
+
 	http.HandleFunc("/", s.indexHandler)
 	http.HandleFunc("/api/monitor", s.monitorHandler)
 	http.HandleFunc("/api/monitor-all", s.monitorAllHandler)
+	http.HandleFunc("/api/silences", s.silencesHandler)
+	http.HandleFunc("/api/templates", s.templatesHandler)
+	http.HandleFunc("/api/rules", s.rulesHandler)
 	return http.ListenAndServe(":"+port, nil)
 }
\ No newline at end of file